@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	externalv1 "gis/polygon/api/external/v1"
+	"gis/polygon/services/external_controller/internal/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const logPollInterval = 2 * time.Second
+
+// fetchLogs читает порцию логов задачи начиная с offset.
+// Смысл offset зависит от бэкенда: для Jenkins и Terraform — байтовое смещение в тексте лога,
+// для Semaphore — количество уже прочитанных строк вывода задачи.
+func (s *Server) fetchLogs(ctx context.Context, job *storage.Job, offset int64) (*externalv1.JobLog, error) {
+	var content string
+	newOffset := offset
+	var moreAvailable bool
+
+	switch externalv1.JobType(job.Type) {
+	case externalv1.JobType_JOB_TYPE_JENKINS:
+		if s.jenkins != nil && job.JenkinsBuildNum > 0 {
+			var err error
+			content, newOffset, moreAvailable, err = s.jenkins.GetBuildLog(ctx, job.JenkinsJobName, job.JenkinsBuildNum, offset)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "get jenkins logs: %v", err)
+			}
+		}
+
+	case externalv1.JobType_JOB_TYPE_TERRAFORM:
+		if s.terraform != nil && job.TerraformRunID != "" {
+			full, err := s.terraform.GetRunLogs(ctx, job.TerraformRunID)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "get terraform logs: %v", err)
+			}
+			if offset < int64(len(full)) {
+				content = full[offset:]
+			}
+			newOffset = int64(len(full))
+		}
+
+	case externalv1.JobType_JOB_TYPE_ANSIBLE:
+		if s.ansible != nil && job.AnsibleTaskID > 0 {
+			outputs, err := s.ansible.GetTaskOutput(ctx, job.AnsibleProjectID, job.AnsibleTaskID)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "get ansible logs: %v", err)
+			}
+			for i := offset; i < int64(len(outputs)); i++ {
+				out := outputs[i]
+				content += fmt.Sprintf("[%s] %s\n%s\n", out.Time, out.Task, out.Output)
+			}
+			if int64(len(outputs)) > offset {
+				newOffset = int64(len(outputs))
+			}
+		}
+	}
+
+	return &externalv1.JobLog{
+		JobId:         job.ID.String(),
+		Content:       content,
+		Offset:        newOffset,
+		MoreAvailable: moreAvailable,
+	}, nil
+}
+
+// WatchJobLogs отдаёт лог задачи потоком: новые порции отправляются по мере появления,
+// пока задача не перейдёт в терминальный статус и лог не будет дочитан до конца.
+func (s *Server) WatchJobLogs(req *externalv1.WatchJobLogsRequest, stream externalv1.ExternalControllerService_WatchJobLogsServer) error {
+	if req.GetJobId() == "" {
+		return status.Error(codes.InvalidArgument, "job_id required")
+	}
+	ctx := stream.Context()
+
+	job, err := s.loadJob(ctx, req.GetJobId())
+	if err != nil {
+		return err
+	}
+
+	offset := req.GetOffset()
+	for {
+		terminal := storage.IsTerminalStatus(job.Status)
+
+		chunk, err := s.fetchLogs(ctx, job, offset)
+		if err != nil {
+			return err
+		}
+		if chunk.GetContent() != "" {
+			chunk.MoreAvailable = chunk.GetMoreAvailable() || !terminal
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+		}
+		offset = chunk.GetOffset()
+
+		if terminal && !chunk.GetMoreAvailable() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(logPollInterval):
+		}
+
+		job, err = s.loadJob(ctx, req.GetJobId())
+		if err != nil {
+			return err
+		}
+		if err := s.syncJob(ctx, job); err != nil {
+			log.Printf("watch logs: refresh job %s: %v", job.ID, err)
+		}
+	}
+}
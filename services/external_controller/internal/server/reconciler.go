@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	externalv1 "gis/polygon/api/external/v1"
+)
+
+const maxReconcileBackoff = 5 * time.Minute
+
+// backendBackoff — состояние экспоненциальной задержки для одного внешнего бэкенда.
+type backendBackoff struct {
+	failures int
+	until    time.Time
+}
+
+// RunReconciler периодически сверяет все незавершённые задачи с Jenkins / Terraform / Semaphore,
+// чтобы статусы обновлялись без участия клиента. При ошибках обращения к бэкенду опрос его задач
+// откладывается с экспоненциально растущей задержкой (не больше maxReconcileBackoff).
+// Первый проход выполняется сразу, так что задачи, оставшиеся незавершёнными до рестарта,
+// подхватываются при старте. Блокируется до отмены ctx.
+func (s *Server) RunReconciler(ctx context.Context, interval time.Duration) {
+	backoff := make(map[externalv1.JobType]*backendBackoff)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		s.reconcileOnce(ctx, interval, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Server) reconcileOnce(ctx context.Context, interval time.Duration, backoff map[externalv1.JobType]*backendBackoff) {
+	jobs, err := s.repo.ListActiveJobs(ctx)
+	if err != nil {
+		log.Printf("reconcile: list active jobs: %v", err)
+		return
+	}
+	now := time.Now()
+	for i := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		jt := externalv1.JobType(jobs[i].Type)
+		b := backoff[jt]
+		if b == nil {
+			b = &backendBackoff{}
+			backoff[jt] = b
+		}
+		if now.Before(b.until) {
+			continue
+		}
+		if err := s.syncJob(ctx, &jobs[i]); err != nil {
+			b.failures++
+			delay := interval << b.failures
+			if delay <= 0 || delay > maxReconcileBackoff {
+				delay = maxReconcileBackoff
+			}
+			b.until = now.Add(delay)
+			log.Printf("reconcile: %s job %s: %v (retry in %s)", jt, jobs[i].ID, err, delay)
+			continue
+		}
+		b.failures = 0
+	}
+}
@@ -3,20 +3,25 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	externalv1 "gis/polygon/api/external/v1"
 	"gis/polygon/services/external_controller/internal/ansible"
 	"gis/polygon/services/external_controller/internal/jenkins"
+	"gis/polygon/services/external_controller/internal/storage"
 	"gis/polygon/services/external_controller/internal/terraform"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 	structpb "google.golang.org/protobuf/types/known/structpb"
@@ -25,38 +30,18 @@ import (
 type Server struct {
 	externalv1.UnimplementedExternalControllerServiceServer
 
+	repo      *storage.Repo
 	jenkins   *jenkins.Client
 	terraform *terraform.Client
 	ansible   *ansible.Client
-
-	jobs   map[string]*jobRecord
-	jobsMu sync.RWMutex
-}
-
-type jobRecord struct {
-	ID               string
-	ExternalID       string
-	Type             externalv1.JobType
-	Status           externalv1.JobStatus
-	Name             string
-	Params           map[string]interface{}
-	CreatedAt        time.Time
-	StartedAt        *time.Time
-	FinishedAt       *time.Time
-	ErrorMessage     string
-	JenkinsJobName   string
-	JenkinsBuildNum  int
-	TerraformRunID   string
-	AnsibleProjectID int
-	AnsibleTaskID    int
 }
 
-func NewServer(jenkinsClient *jenkins.Client, terraformClient *terraform.Client, ansibleClient *ansible.Client) *Server {
+func NewServer(repo *storage.Repo, jenkinsClient *jenkins.Client, terraformClient *terraform.Client, ansibleClient *ansible.Client) *Server {
 	return &Server{
+		repo:      repo,
 		jenkins:   jenkinsClient,
 		terraform: terraformClient,
 		ansible:   ansibleClient,
-		jobs:      make(map[string]*jobRecord),
 	}
 }
 
@@ -80,20 +65,21 @@ func (s *Server) RunJenkinsJob(ctx context.Context, req *externalv1.RunJenkinsJo
 		return nil, status.Errorf(codes.Internal, "trigger jenkins build: %v", err)
 	}
 
-	job := &jobRecord{
-		ID:             uuid.New().String(),
+	job := &storage.Job{
+		ID:             uuid.New(),
 		ExternalID:     fmt.Sprintf("%d", queueID),
-		Type:           externalv1.JobType_JOB_TYPE_JENKINS,
-		Status:         externalv1.JobStatus_JOB_STATUS_PENDING,
+		Type:           int32(externalv1.JobType_JOB_TYPE_JENKINS),
+		Status:         int32(externalv1.JobStatus_JOB_STATUS_PENDING),
 		Name:           req.GetJobName(),
-		Params:         req.GetParams().AsMap(),
+		Params:         json.RawMessage(MarshalParams(req.GetParams().AsMap())),
 		CreatedAt:      time.Now(),
 		JenkinsJobName: req.GetJobName(),
+		CreatedBy:      callerID(ctx),
 	}
 
-	s.jobsMu.Lock()
-	s.jobs[job.ID] = job
-	s.jobsMu.Unlock()
+	if err := s.repo.CreateJob(ctx, job); err != nil {
+		return nil, status.Errorf(codes.Internal, "save job: %v", err)
+	}
 
 	return jobToProto(job), nil
 }
@@ -122,21 +108,22 @@ func (s *Server) RunTerraform(ctx context.Context, req *externalv1.RunTerraformR
 	}
 
 	now := time.Now()
-	job := &jobRecord{
-		ID:             uuid.New().String(),
+	job := &storage.Job{
+		ID:             uuid.New(),
 		ExternalID:     run.ID,
-		Type:           externalv1.JobType_JOB_TYPE_TERRAFORM,
-		Status:         externalv1.JobStatus_JOB_STATUS_RUNNING,
+		Type:           int32(externalv1.JobType_JOB_TYPE_TERRAFORM),
+		Status:         int32(externalv1.JobStatus_JOB_STATUS_RUNNING),
 		Name:           fmt.Sprintf("%s:%s", req.GetWorkspace(), req.GetAction()),
-		Params:         req.GetVars().AsMap(),
+		Params:         json.RawMessage(MarshalParams(req.GetVars().AsMap())),
 		CreatedAt:      now,
 		StartedAt:      &now,
 		TerraformRunID: run.ID,
+		CreatedBy:      callerID(ctx),
 	}
 
-	s.jobsMu.Lock()
-	s.jobs[job.ID] = job
-	s.jobsMu.Unlock()
+	if err := s.repo.CreateJob(ctx, job); err != nil {
+		return nil, status.Errorf(codes.Internal, "save job: %v", err)
+	}
 
 	return jobToProto(job), nil
 }
@@ -160,22 +147,23 @@ func (s *Server) RunAnsible(ctx context.Context, req *externalv1.RunAnsibleReque
 	}
 
 	now := time.Now()
-	job := &jobRecord{
-		ID:               uuid.New().String(),
+	job := &storage.Job{
+		ID:               uuid.New(),
 		ExternalID:       strconv.Itoa(task.ID),
-		Type:             externalv1.JobType_JOB_TYPE_ANSIBLE,
-		Status:           externalv1.JobStatus_JOB_STATUS_RUNNING,
+		Type:             int32(externalv1.JobType_JOB_TYPE_ANSIBLE),
+		Status:           int32(externalv1.JobStatus_JOB_STATUS_RUNNING),
 		Name:             fmt.Sprintf("project:%d/template:%d", req.GetProjectId(), req.GetTemplateId()),
-		Params:           extraVars,
+		Params:           json.RawMessage(MarshalParams(extraVars)),
 		CreatedAt:        now,
 		StartedAt:        &now,
 		AnsibleProjectID: int(req.GetProjectId()),
 		AnsibleTaskID:    task.ID,
+		CreatedBy:        callerID(ctx),
 	}
 
-	s.jobsMu.Lock()
-	s.jobs[job.ID] = job
-	s.jobsMu.Unlock()
+	if err := s.repo.CreateJob(ctx, job); err != nil {
+		return nil, status.Errorf(codes.Internal, "save job: %v", err)
+	}
 
 	return jobToProto(job), nil
 }
@@ -185,16 +173,13 @@ func (s *Server) GetJobStatus(ctx context.Context, req *externalv1.GetJobStatusR
 		return nil, status.Error(codes.InvalidArgument, "job_id required")
 	}
 
-	s.jobsMu.RLock()
-	job, ok := s.jobs[req.GetJobId()]
-	s.jobsMu.RUnlock()
-
-	if !ok {
-		return nil, status.Error(codes.NotFound, "job not found")
+	job, err := s.loadJob(ctx, req.GetJobId())
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.refreshJobStatus(ctx, job); err != nil {
-		fmt.Printf("refresh job status error: %v\n", err)
+	if err := s.syncJob(ctx, job); err != nil {
+		log.Printf("refresh job status error: %v", err)
 	}
 
 	return jobToProto(job), nil
@@ -205,55 +190,12 @@ func (s *Server) GetJobLogs(ctx context.Context, req *externalv1.GetJobLogsReque
 		return nil, status.Error(codes.InvalidArgument, "job_id required")
 	}
 
-	s.jobsMu.RLock()
-	job, ok := s.jobs[req.GetJobId()]
-	s.jobsMu.RUnlock()
-
-	if !ok {
-		return nil, status.Error(codes.NotFound, "job not found")
-	}
-
-	var content string
-	var newOffset int64
-	var moreAvailable bool
-
-	switch job.Type {
-	case externalv1.JobType_JOB_TYPE_JENKINS:
-		if s.jenkins != nil && job.JenkinsBuildNum > 0 {
-			var err error
-			content, newOffset, moreAvailable, err = s.jenkins.GetBuildLog(ctx, job.JenkinsJobName, job.JenkinsBuildNum, req.GetOffset())
-			if err != nil {
-				return nil, status.Errorf(codes.Internal, "get jenkins logs: %v", err)
-			}
-		}
-
-	case externalv1.JobType_JOB_TYPE_TERRAFORM:
-		if s.terraform != nil && job.TerraformRunID != "" {
-			var err error
-			content, err = s.terraform.GetRunLogs(ctx, job.TerraformRunID)
-			if err != nil {
-				return nil, status.Errorf(codes.Internal, "get terraform logs: %v", err)
-			}
-		}
-
-	case externalv1.JobType_JOB_TYPE_ANSIBLE:
-		if s.ansible != nil && job.AnsibleTaskID > 0 {
-			outputs, err := s.ansible.GetTaskOutput(ctx, job.AnsibleProjectID, job.AnsibleTaskID)
-			if err != nil {
-				return nil, status.Errorf(codes.Internal, "get ansible logs: %v", err)
-			}
-			for _, out := range outputs {
-				content += fmt.Sprintf("[%s] %s\n%s\n", out.Time, out.Task, out.Output)
-			}
-		}
+	job, err := s.loadJob(ctx, req.GetJobId())
+	if err != nil {
+		return nil, err
 	}
 
-	return &externalv1.JobLog{
-		JobId:         job.ID,
-		Content:       content,
-		Offset:        newOffset,
-		MoreAvailable: moreAvailable,
-	}, nil
+	return s.fetchLogs(ctx, job, req.GetOffset())
 }
 
 func (s *Server) CancelJob(ctx context.Context, req *externalv1.CancelJobRequest) (*emptypb.Empty, error) {
@@ -261,15 +203,12 @@ func (s *Server) CancelJob(ctx context.Context, req *externalv1.CancelJobRequest
 		return nil, status.Error(codes.InvalidArgument, "job_id required")
 	}
 
-	s.jobsMu.RLock()
-	job, ok := s.jobs[req.GetJobId()]
-	s.jobsMu.RUnlock()
-
-	if !ok {
-		return nil, status.Error(codes.NotFound, "job not found")
+	job, err := s.loadJob(ctx, req.GetJobId())
+	if err != nil {
+		return nil, err
 	}
 
-	switch job.Type {
+	switch externalv1.JobType(job.Type) {
 	case externalv1.JobType_JOB_TYPE_JENKINS:
 		if s.jenkins != nil && job.JenkinsBuildNum > 0 {
 			if err := s.jenkins.StopBuild(ctx, job.JenkinsJobName, job.JenkinsBuildNum); err != nil {
@@ -292,42 +231,34 @@ func (s *Server) CancelJob(ctx context.Context, req *externalv1.CancelJobRequest
 		}
 	}
 
-	s.jobsMu.Lock()
-	job.Status = externalv1.JobStatus_JOB_STATUS_CANCELLED
+	job.Status = int32(externalv1.JobStatus_JOB_STATUS_CANCELLED)
 	now := time.Now()
 	job.FinishedAt = &now
-	s.jobsMu.Unlock()
+	if err := s.repo.UpdateJob(ctx, job); err != nil {
+		return nil, status.Errorf(codes.Internal, "save job: %v", err)
+	}
 
 	return &emptypb.Empty{}, nil
 }
 
 func (s *Server) ListJobs(ctx context.Context, req *externalv1.ListJobsRequest) (*externalv1.ListJobsResponse, error) {
-	s.jobsMu.RLock()
-	defer s.jobsMu.RUnlock()
-
-	var jobs []*externalv1.Job
-	for _, job := range s.jobs {
-		if req.GetType() != externalv1.JobType_JOB_TYPE_UNSPECIFIED && job.Type != req.GetType() {
-			continue
-		}
-		jobs = append(jobs, jobToProto(job))
-	}
-
-	total := len(jobs)
 	offset := int(req.GetOffset())
 	limit := int(req.GetLimit())
-	if limit == 0 {
+	if limit <= 0 {
 		limit = 50
 	}
+	if offset < 0 {
+		offset = 0
+	}
 
-	if offset >= len(jobs) {
-		jobs = nil
-	} else {
-		end := offset + limit
-		if end > len(jobs) {
-			end = len(jobs)
-		}
-		jobs = jobs[offset:end]
+	list, total, err := s.repo.ListJobs(ctx, int32(req.GetType()), limit, offset)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list jobs: %v", err)
+	}
+
+	jobs := make([]*externalv1.Job, 0, len(list))
+	for i := range list {
+		jobs = append(jobs, jobToProto(&list[i]))
 	}
 
 	return &externalv1.ListJobsResponse{
@@ -336,8 +267,90 @@ func (s *Server) ListJobs(ctx context.Context, req *externalv1.ListJobsRequest)
 	}, nil
 }
 
-func (s *Server) refreshJobStatus(ctx context.Context, job *jobRecord) error {
-	switch job.Type {
+func (s *Server) ListJobEvents(ctx context.Context, req *externalv1.ListJobEventsRequest) (*externalv1.ListJobEventsResponse, error) {
+	if req.GetJobId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "job_id required")
+	}
+
+	job, err := s.loadJob(ctx, req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := s.repo.ListJobEvents(ctx, job.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list job events: %v", err)
+	}
+
+	events := make([]*externalv1.JobEvent, 0, len(list))
+	for _, e := range list {
+		events = append(events, &externalv1.JobEvent{
+			JobId:      e.JobID.String(),
+			FromStatus: externalv1.JobStatus(e.FromStatus),
+			ToStatus:   externalv1.JobStatus(e.ToStatus),
+			CreatedAt:  e.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return &externalv1.ListJobEventsResponse{Events: events}, nil
+}
+
+// callerID — id пользователя, проброшенный gateway в x-user-id. Для системных вызовов
+// (например, развёртывание лаб из polygon) метаданных нет, и job сохраняется без автора.
+func callerID(ctx context.Context) *uuid.UUID {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	for _, v := range md.Get("x-user-id") {
+		if id, err := uuid.Parse(strings.TrimSpace(v)); err == nil {
+			return &id
+		}
+	}
+	return nil
+}
+
+// loadJob достаёт задачу по строковому id и переводит ошибки хранилища в gRPC статусы.
+func (s *Server) loadJob(ctx context.Context, id string) (*storage.Job, error) {
+	jobID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid job_id")
+	}
+	job, err := s.repo.GetJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "job not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get job: %v", err)
+	}
+	return job, nil
+}
+
+// syncJob сверяет статус незавершённой задачи с внешней системой и сохраняет изменения.
+func (s *Server) syncJob(ctx context.Context, job *storage.Job) error {
+	if storage.IsTerminalStatus(job.Status) {
+		return nil
+	}
+	prev := *job
+	if err := s.refreshJobStatus(ctx, job); err != nil {
+		return err
+	}
+	if jobChanged(&prev, job) {
+		return s.repo.UpdateJob(ctx, job)
+	}
+	return nil
+}
+
+func jobChanged(a, b *storage.Job) bool {
+	return a.Status != b.Status ||
+		a.JenkinsBuildNum != b.JenkinsBuildNum ||
+		a.ErrorMessage != b.ErrorMessage ||
+		(a.StartedAt == nil) != (b.StartedAt == nil) ||
+		(a.FinishedAt == nil) != (b.FinishedAt == nil)
+}
+
+func (s *Server) refreshJobStatus(ctx context.Context, job *storage.Job) error {
+	switch externalv1.JobType(job.Type) {
 	case externalv1.JobType_JOB_TYPE_JENKINS:
 		if s.jenkins == nil {
 			return nil
@@ -352,7 +365,7 @@ func (s *Server) refreshJobStatus(ctx context.Context, job *jobRecord) error {
 				job.JenkinsBuildNum = queueItem.Executable.Number
 				now := time.Now()
 				job.StartedAt = &now
-				job.Status = externalv1.JobStatus_JOB_STATUS_RUNNING
+				job.Status = int32(externalv1.JobStatus_JOB_STATUS_RUNNING)
 			}
 		}
 
@@ -362,17 +375,17 @@ func (s *Server) refreshJobStatus(ctx context.Context, job *jobRecord) error {
 				return err
 			}
 			if info.Building {
-				job.Status = externalv1.JobStatus_JOB_STATUS_RUNNING
+				job.Status = int32(externalv1.JobStatus_JOB_STATUS_RUNNING)
 			} else {
 				now := time.Now()
 				job.FinishedAt = &now
 				switch info.Result {
 				case "SUCCESS":
-					job.Status = externalv1.JobStatus_JOB_STATUS_SUCCESS
+					job.Status = int32(externalv1.JobStatus_JOB_STATUS_SUCCESS)
 				case "FAILURE":
-					job.Status = externalv1.JobStatus_JOB_STATUS_FAILED
+					job.Status = int32(externalv1.JobStatus_JOB_STATUS_FAILED)
 				case "ABORTED":
-					job.Status = externalv1.JobStatus_JOB_STATUS_CANCELLED
+					job.Status = int32(externalv1.JobStatus_JOB_STATUS_CANCELLED)
 				}
 			}
 		}
@@ -387,17 +400,17 @@ func (s *Server) refreshJobStatus(ctx context.Context, job *jobRecord) error {
 		}
 		switch terraform.StatusToJobStatus(run.Status) {
 		case "running":
-			job.Status = externalv1.JobStatus_JOB_STATUS_RUNNING
+			job.Status = int32(externalv1.JobStatus_JOB_STATUS_RUNNING)
 		case "success":
-			job.Status = externalv1.JobStatus_JOB_STATUS_SUCCESS
+			job.Status = int32(externalv1.JobStatus_JOB_STATUS_SUCCESS)
 			now := time.Now()
 			job.FinishedAt = &now
 		case "failed":
-			job.Status = externalv1.JobStatus_JOB_STATUS_FAILED
+			job.Status = int32(externalv1.JobStatus_JOB_STATUS_FAILED)
 			now := time.Now()
 			job.FinishedAt = &now
 		case "cancelled":
-			job.Status = externalv1.JobStatus_JOB_STATUS_CANCELLED
+			job.Status = int32(externalv1.JobStatus_JOB_STATUS_CANCELLED)
 			now := time.Now()
 			job.FinishedAt = &now
 		}
@@ -412,18 +425,18 @@ func (s *Server) refreshJobStatus(ctx context.Context, job *jobRecord) error {
 		}
 		switch ansible.StatusToJobStatus(task.Status) {
 		case "running":
-			job.Status = externalv1.JobStatus_JOB_STATUS_RUNNING
+			job.Status = int32(externalv1.JobStatus_JOB_STATUS_RUNNING)
 		case "success":
-			job.Status = externalv1.JobStatus_JOB_STATUS_SUCCESS
+			job.Status = int32(externalv1.JobStatus_JOB_STATUS_SUCCESS)
 			now := time.Now()
 			job.FinishedAt = &now
 		case "failed":
-			job.Status = externalv1.JobStatus_JOB_STATUS_FAILED
+			job.Status = int32(externalv1.JobStatus_JOB_STATUS_FAILED)
 			job.ErrorMessage = task.Message
 			now := time.Now()
 			job.FinishedAt = &now
 		case "cancelled":
-			job.Status = externalv1.JobStatus_JOB_STATUS_CANCELLED
+			job.Status = int32(externalv1.JobStatus_JOB_STATUS_CANCELLED)
 			now := time.Now()
 			job.FinishedAt = &now
 		}
@@ -432,19 +445,25 @@ func (s *Server) refreshJobStatus(ctx context.Context, job *jobRecord) error {
 	return nil
 }
 
-func jobToProto(job *jobRecord) *externalv1.Job {
+func jobToProto(job *storage.Job) *externalv1.Job {
 	pb := &externalv1.Job{
-		Id:           job.ID,
+		Id:           job.ID.String(),
 		ExternalId:   job.ExternalID,
-		Type:         job.Type,
-		Status:       job.Status,
+		Type:         externalv1.JobType(job.Type),
+		Status:       externalv1.JobStatus(job.Status),
 		Name:         job.Name,
 		CreatedAt:    job.CreatedAt.Format(time.RFC3339),
 		ErrorMessage: job.ErrorMessage,
 	}
 
-	if job.Params != nil {
-		pb.Params, _ = structpb.NewStruct(job.Params)
+	if len(job.Params) > 0 {
+		var m map[string]interface{}
+		if json.Unmarshal(job.Params, &m) == nil {
+			pb.Params, _ = structpb.NewStruct(m)
+		}
+	}
+	if job.CreatedBy != nil {
+		pb.CreatedBy = job.CreatedBy.String()
 	}
 	if job.StartedAt != nil {
 		pb.StartedAt = job.StartedAt.Format(time.RFC3339)
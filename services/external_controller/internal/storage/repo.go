@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Значения статусов совпадают с externalv1.JobStatus.
+const (
+	JobStatusPending   int32 = 1
+	JobStatusRunning   int32 = 2
+	JobStatusSuccess   int32 = 3
+	JobStatusFailed    int32 = 4
+	JobStatusCancelled int32 = 5
+)
+
+type Repo struct{ pool *pgxpool.Pool }
+
+func NewRepo(p *pgxpool.Pool) *Repo { return &Repo{pool: p} }
+
+type Job struct {
+	ID               uuid.UUID
+	ExternalID       string
+	Type             int32
+	Status           int32
+	Name             string
+	Params           json.RawMessage
+	CreatedAt        time.Time
+	StartedAt        *time.Time
+	FinishedAt       *time.Time
+	ErrorMessage     string
+	JenkinsJobName   string
+	JenkinsBuildNum  int
+	TerraformRunID   string
+	AnsibleProjectID int
+	AnsibleTaskID    int
+	CreatedBy        *uuid.UUID
+}
+
+func (r *Repo) Migrate(ctx context.Context) error {
+	stmts := []string{
+		`create table if not exists external_jobs(
+			id uuid primary key,
+			external_id text not null default '',
+			type smallint not null,
+			status smallint not null,
+			name text not null,
+			params jsonb not null default '{}',
+			error_message text not null default '',
+			jenkins_job_name text not null default '',
+			jenkins_build_num int not null default 0,
+			terraform_run_id text not null default '',
+			ansible_project_id int not null default 0,
+			ansible_task_id int not null default 0,
+			created_at timestamptz not null default now(),
+			started_at timestamptz,
+			finished_at timestamptz,
+			updated_at timestamptz not null default now()
+		);`,
+		`create index if not exists idx_external_jobs_status on external_jobs(status);`,
+		`create index if not exists idx_external_jobs_created on external_jobs(created_at);`,
+		`create table if not exists external_job_events(
+			id bigserial primary key,
+			job_id uuid not null references external_jobs(id) on delete cascade,
+			from_status smallint not null,
+			to_status smallint not null,
+			created_at timestamptz not null default now()
+		);`,
+		`create index if not exists idx_external_job_events_job on external_job_events(job_id);`,
+		`alter table external_jobs add column if not exists created_by uuid`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateJob сохраняет новую задачу и фиксирует её начальный статус в истории переходов.
+func (r *Repo) CreateJob(ctx context.Context, j *Job) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, `insert into external_jobs(id, external_id, type, status, name, params, error_message, jenkins_job_name, jenkins_build_num, terraform_run_id, ansible_project_id, ansible_task_id, created_at, started_at, finished_at, created_by)
+		values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`,
+		j.ID, j.ExternalID, j.Type, j.Status, j.Name, j.Params, j.ErrorMessage, j.JenkinsJobName, j.JenkinsBuildNum, j.TerraformRunID, j.AnsibleProjectID, j.AnsibleTaskID, j.CreatedAt, j.StartedAt, j.FinishedAt, j.CreatedBy)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `insert into external_job_events(job_id, from_status, to_status, created_at) values ($1,0,$2,$3)`, j.ID, j.Status, j.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UpdateJob сохраняет изменяемые поля задачи. Строка блокируется на время транзакции:
+// если статус изменился, в историю пишется переход из текущего сохранённого статуса;
+// задачу, уже находящуюся в терминальном статусе, повторно не перезаписываем.
+func (r *Repo) UpdateJob(ctx context.Context, j *Job) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var cur int32
+	if err := tx.QueryRow(ctx, `select status from external_jobs where id=$1 for update`, j.ID).Scan(&cur); err != nil {
+		return err
+	}
+	if IsTerminalStatus(cur) {
+		return nil
+	}
+	_, err = tx.Exec(ctx, `update external_jobs set status=$2, external_id=$3, error_message=$4, jenkins_build_num=$5, terraform_run_id=$6, ansible_task_id=$7, started_at=$8, finished_at=$9, updated_at=now() where id=$1`,
+		j.ID, j.Status, j.ExternalID, j.ErrorMessage, j.JenkinsBuildNum, j.TerraformRunID, j.AnsibleTaskID, j.StartedAt, j.FinishedAt)
+	if err != nil {
+		return err
+	}
+	if cur != j.Status {
+		if _, err := tx.Exec(ctx, `insert into external_job_events(job_id, from_status, to_status) values ($1,$2,$3)`, j.ID, cur, j.Status); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repo) GetJob(ctx context.Context, id uuid.UUID) (*Job, error) {
+	row := r.pool.QueryRow(ctx, `select `+jobColumns+` from external_jobs where id=$1`, id)
+	return scanJob(row)
+}
+
+// ListJobs возвращает страницу задач (новые сверху) и общее количество. jobType=0 — все типы.
+func (r *Repo) ListJobs(ctx context.Context, jobType int32, limit, offset int) ([]Job, int, error) {
+	var total int
+	if err := r.pool.QueryRow(ctx, `select count(*) from external_jobs where $1=0 or type=$1`, jobType).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.pool.Query(ctx, `select `+jobColumns+` from external_jobs where $1=0 or type=$1 order by created_at desc limit $2 offset $3`, jobType, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var res []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		res = append(res, *j)
+	}
+	return res, total, rows.Err()
+}
+
+// ListActiveJobs возвращает задачи в нетерминальных статусах (PENDING / RUNNING).
+func (r *Repo) ListActiveJobs(ctx context.Context) ([]Job, error) {
+	rows, err := r.pool.Query(ctx, `select `+jobColumns+` from external_jobs where status in ($1,$2) order by created_at`, JobStatusPending, JobStatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *j)
+	}
+	return res, rows.Err()
+}
+
+type JobEvent struct {
+	ID         int64
+	JobID      uuid.UUID
+	FromStatus int32
+	ToStatus   int32
+	CreatedAt  time.Time
+}
+
+// ListJobEvents возвращает историю смены статусов задачи в хронологическом порядке.
+func (r *Repo) ListJobEvents(ctx context.Context, jobID uuid.UUID) ([]JobEvent, error) {
+	rows, err := r.pool.Query(ctx, `select id, job_id, from_status, to_status, created_at from external_job_events where job_id=$1 order by created_at, id`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []JobEvent
+	for rows.Next() {
+		var e JobEvent
+		if err := rows.Scan(&e.ID, &e.JobID, &e.FromStatus, &e.ToStatus, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
+
+// IsTerminalStatus — true для SUCCESS / FAILED / CANCELLED.
+func IsTerminalStatus(st int32) bool {
+	return st == JobStatusSuccess || st == JobStatusFailed || st == JobStatusCancelled
+}
+
+const jobColumns = `id, external_id, type, status, name, params, error_message, jenkins_job_name, jenkins_build_num, terraform_run_id, ansible_project_id, ansible_task_id, created_at, started_at, finished_at, created_by`
+
+func scanJob(row pgx.Row) (*Job, error) {
+	var j Job
+	if err := row.Scan(&j.ID, &j.ExternalID, &j.Type, &j.Status, &j.Name, &j.Params, &j.ErrorMessage, &j.JenkinsJobName, &j.JenkinsBuildNum, &j.TerraformRunID, &j.AnsibleProjectID, &j.AnsibleTaskID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.CreatedBy); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	externalv1 "gis/polygon/api/external/v1"
+
+	"gis/polygon/services/gateway/internal/middleware"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// registerJobLogsSSE публикует поток логов задачи external_controller в формате Server-Sent Events:
+// GET /v1/admin/external/jobs/{job_id}/logs/sse[?offset=N]. Каждая порция лога уходит событием
+// "log" с JSON JobLog в data, по завершении задачи отправляется событие "end".
+// Тот же поток в виде chunked JSON доступен через grpc-gateway по .../logs/watch.
+func registerJobLogsSSE(ctx context.Context, mux *runtime.ServeMux, addr string, opts []grpc.DialOption) error {
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	client := externalv1.NewExternalControllerServiceClient(conn)
+
+	return mux.HandlePath(http.MethodGet, "/v1/admin/external/jobs/{job_id}/logs/sse", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if !middleware.IsAdmin(r.Context()) {
+			http.Error(w, "admin_access_required", http.StatusForbidden)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		var offset int64
+		if v := r.URL.Query().Get("offset"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid offset", http.StatusBadRequest)
+				return
+			}
+			offset = n
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		md := metadata.Pairs("x-user-id", middleware.GetUserID(r.Context()), "x-user-role", string(middleware.GetRole(r.Context())))
+		stream, err := client.WatchJobLogs(metadata.NewOutgoingContext(r.Context(), md), &externalv1.WatchJobLogsRequest{
+			JobId:  params["job_id"],
+			Offset: offset,
+		})
+		if err != nil {
+			writeSSEError(w, err)
+			flusher.Flush()
+			return
+		}
+
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				fmt.Fprint(w, "event: end\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			if err != nil {
+				writeSSEError(w, err)
+				flusher.Flush()
+				return
+			}
+			data, err := protojson.Marshal(chunk)
+			if err != nil {
+				writeSSEError(w, err)
+				flusher.Flush()
+				return
+			}
+			fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	})
+}
+
+func writeSSEError(w io.Writer, err error) {
+	msg := err.Error()
+	if st, ok := status.FromError(err); ok {
+		msg = st.Message()
+	}
+	data, _ := json.Marshal(map[string]string{"error": msg})
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+}
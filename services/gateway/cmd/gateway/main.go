@@ -10,6 +10,8 @@ import (
 
 	attv1 "gis/polygon/api/attachments/v1"
 	authv1 "gis/polygon/api/auth/v1"
+	externalv1 "gis/polygon/api/external/v1"
+	labv1 "gis/polygon/api/lab/v1"
 	newsv1 "gis/polygon/api/news/v1"
 	polygonv1 "gis/polygon/api/polygon/v1"
 	usersv1 "gis/polygon/api/users/v1"
@@ -93,6 +95,9 @@ func main() {
 	if err := polygonv1.RegisterPolygonClientServiceHandlerFromEndpoint(ctx, mux, polygonAddr, dialOpts); err != nil {
 		log.Fatalf("register polygon client handler: %v", err)
 	}
+	if err := labv1.RegisterLabClientServiceHandlerFromEndpoint(ctx, mux, polygonAddr, dialOpts); err != nil {
+		log.Fatalf("register lab client handler: %v", err)
+	}
 	if err := authv1.RegisterAuthClientServiceHandlerFromEndpoint(ctx, mux, authAddr, dialOpts); err != nil {
 		log.Fatalf("register auth client handler: %v", err)
 	}
@@ -109,6 +114,9 @@ func main() {
 	if err := polygonv1.RegisterPolygonAdminServiceHandlerFromEndpoint(ctx, mux, polygonAddr, dialOpts); err != nil {
 		log.Fatalf("register polygon admin handler: %v", err)
 	}
+	if err := labv1.RegisterLabAdminServiceHandlerFromEndpoint(ctx, mux, polygonAddr, dialOpts); err != nil {
+		log.Fatalf("register lab admin handler: %v", err)
+	}
 	if err := authv1.RegisterAuthAdminServiceHandlerFromEndpoint(ctx, mux, authAddr, dialOpts); err != nil {
 		log.Fatalf("register auth admin handler: %v", err)
 	}
@@ -116,7 +124,12 @@ func main() {
 		log.Fatalf("register attachments admin handler: %v", err)
 	}
 
-	_ = registerExternalController(ctx, mux, externalControllerAddr, dialOpts)
+	if err := registerExternalController(ctx, mux, externalControllerAddr, dialOpts); err != nil {
+		log.Fatalf("register external controller handler: %v", err)
+	}
+	if err := registerJobLogsSSE(ctx, mux, externalControllerAddr, dialOpts); err != nil {
+		log.Fatalf("register external job logs stream: %v", err)
+	}
 
 	authMiddleware := middleware.NewAuthMiddleware([]byte(jwtSecret))
 
@@ -137,12 +150,10 @@ func main() {
 	}
 }
 
+// registerExternalController публикует /v1/admin/external/*. Доступ только для админов обеспечивает
+// AuthMiddleware, id админа уходит в external_controller через x-user-id.
 func registerExternalController(ctx context.Context, mux *runtime.ServeMux, addr string, opts []grpc.DialOption) error {
-	_ = ctx
-	_ = mux
-	_ = addr
-	_ = opts
-	return nil
+	return externalv1.RegisterExternalControllerServiceHandlerFromEndpoint(ctx, mux, addr, opts)
 }
 
 func configureCORS(handler http.Handler) http.Handler {
@@ -34,6 +34,17 @@ type Claims struct {
 type AuthMiddleware struct {
 	jwtSecret   []byte
 	publicPaths []string
+	// signedPaths всегда требуют токен, кроме GET с подписанной ссылкой (?signature=...):
+	// подпись и срок действия проверяет сервис-владелец.
+	signedPaths []string
+}
+
+// identityHeaders — метаданные с личностью вызывающего проставляет только gateway; клиентские
+// Grpc-Metadata-* заголовки с теми же именами отбрасываются.
+var identityHeaders = []string{
+	"Grpc-Metadata-X-User-Id",
+	"Grpc-Metadata-X-Team-Id",
+	"Grpc-Metadata-X-User-Role",
 }
 
 func NewAuthMiddleware(jwtSecret []byte) *AuthMiddleware {
@@ -44,11 +55,18 @@ func NewAuthMiddleware(jwtSecret []byte) *AuthMiddleware {
 			"/v1/auth/register",
 			"/v1/auth/refresh",
 		},
+		signedPaths: []string{
+			"/v1/report/attachments/",
+		},
 	}
 }
 
 func (m *AuthMiddleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range identityHeaders {
+			r.Header.Del(h)
+		}
+
 		for _, p := range m.publicPaths {
 			if strings.HasPrefix(r.URL.Path, p) {
 				next.ServeHTTP(w, r)
@@ -56,8 +74,14 @@ func (m *AuthMiddleware) Handler(next http.Handler) http.Handler {
 			}
 		}
 
+		isAdminPath := strings.HasPrefix(r.URL.Path, "/v1/admin/")
+
 		authz := r.Header.Get("Authorization")
 		if authz == "" {
+			if isAdminPath || m.requiresToken(r) {
+				writeAuthError(w, http.StatusUnauthorized, "authorization_required")
+				return
+			}
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -74,7 +98,7 @@ func (m *AuthMiddleware) Handler(next http.Handler) http.Handler {
 			return
 		}
 
-		if strings.HasPrefix(r.URL.Path, "/v1/admin/") {
+		if isAdminPath {
 			if claims.Role != RoleAdmin {
 				writeAuthError(w, http.StatusForbidden, "admin_access_required")
 				return
@@ -92,6 +116,15 @@ func (m *AuthMiddleware) Handler(next http.Handler) http.Handler {
 	})
 }
 
+func (m *AuthMiddleware) requiresToken(r *http.Request) bool {
+	for _, p := range m.signedPaths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return r.Method != http.MethodGet || r.URL.Query().Get("signature") == ""
+		}
+	}
+	return false
+}
+
 func (m *AuthMiddleware) validateToken(tokenString string) (*Claims, error) {
 	parsed, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
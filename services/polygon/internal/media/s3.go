@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -79,6 +80,24 @@ func (s *S3Storage) DeleteObject(ctx context.Context, objectKey string) error {
 	return s.client.RemoveObject(ctx, s.bucket, objectKey, minio.RemoveObjectOptions{})
 }
 
+// ObjectInfo — ключ и время изменения объекта бакета.
+type ObjectInfo struct {
+	Key          string
+	LastModified time.Time
+}
+
+// ListObjects — все объекты бакета с префиксом prefix (рекурсивно).
+func (s *S3Storage) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var res []ObjectInfo
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		res = append(res, ObjectInfo{Key: obj.Key, LastModified: obj.LastModified})
+	}
+	return res, nil
+}
+
 func (s *S3Storage) ObjectKey(prefix, id string, filename string) string {
 	key := strings.Trim(prefix, "/") + "/" + id
 	if filename != "" {
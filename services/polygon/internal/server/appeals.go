@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (s *PolygonServer) CreateAppeal(ctx context.Context, req *pb.CreateAppealRequest) (*pb.Appeal, error) {
+	justification := strings.TrimSpace(req.GetJustification())
+	if justification == "" {
+		return nil, status.Error(codes.InvalidArgument, "justification required")
+	}
+	reportID, err := parseOptionalUUID(req.GetReportId(), "report_id")
+	if err != nil {
+		return nil, err
+	}
+	fineID, err := parseOptionalUUID(req.GetFineId(), "fine_id")
+	if err != nil {
+		return nil, err
+	}
+	if (reportID == nil) == (fineID == nil) {
+		return nil, status.Error(codes.InvalidArgument, "exactly one of report_id or fine_id required")
+	}
+	authorID, err := s.callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, teamIDStr, _ := s.extractAuth(ctx)
+	teamID, err := uuid.Parse(teamIDStr)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, "team required")
+	}
+	a := &storage.Appeal{TeamID: teamID, ReportID: reportID, FineID: fineID, AuthorID: authorID, Justification: justification}
+	if reportID != nil {
+		rp, err := s.repo.GetReport(ctx, *reportID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, status.Error(codes.NotFound, "report not found")
+			}
+			return nil, status.Errorf(codes.Internal, "get: %v", err)
+		}
+		if rp.TeamID != teamID {
+			return nil, status.Error(codes.PermissionDenied, "forbidden")
+		}
+		switch pb.ReportStatus(rp.Status) {
+		case pb.ReportStatus_REPORT_STATUS_REJECTED, pb.ReportStatus_REPORT_STATUS_PARTIALLY_ACCEPTED:
+		default:
+			return nil, status.Error(codes.FailedPrecondition, "only rejected or partially accepted reports can be appealed")
+		}
+		a.EventID = rp.EventID
+	} else {
+		f, err := s.repo.GetTeamFine(ctx, *fineID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, status.Error(codes.NotFound, "fine not found")
+			}
+			return nil, status.Errorf(codes.Internal, "get fine: %v", err)
+		}
+		if f.TeamID != teamID {
+			return nil, status.Error(codes.PermissionDenied, "forbidden")
+		}
+		if f.RevokedAt != nil {
+			return nil, status.Error(codes.FailedPrecondition, "fine already revoked")
+		}
+		a.EventID = f.EventID
+	}
+	if err := s.repo.InsertAppeal(ctx, a); err != nil {
+		if errors.Is(err, storage.ErrAppealPending) {
+			return nil, status.Error(codes.AlreadyExists, "appeal already pending")
+		}
+		return nil, status.Errorf(codes.Internal, "create appeal: %v", err)
+	}
+	return toPBAppeal(a), nil
+}
+
+func (s *PolygonServer) GetMyAppeals(ctx context.Context, _ *emptypb.Empty) (*pb.ListAppealsResponse, error) {
+	_, teamIDStr, err := s.extractAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	teamID, err := uuid.Parse(teamIDStr)
+	if err != nil {
+		return &pb.ListAppealsResponse{}, nil
+	}
+	return s.listAppeals(ctx, storage.AppealFilter{TeamID: &teamID})
+}
+
+func (s *PolygonServer) ListAppeals(ctx context.Context, req *pb.ListAppealsRequest) (*pb.ListAppealsResponse, error) {
+	var (
+		f   storage.AppealFilter
+		err error
+	)
+	if f.TeamID, err = parseOptionalUUID(req.GetTeamId(), "team_id"); err != nil {
+		return nil, err
+	}
+	if f.EventID, err = parseOptionalUUID(req.GetEventId(), "event_id"); err != nil {
+		return nil, err
+	}
+	if req.Status != nil {
+		st := int32(req.GetStatus())
+		f.Status = &st
+	}
+	return s.listAppeals(ctx, f)
+}
+
+func (s *PolygonServer) listAppeals(ctx context.Context, f storage.AppealFilter) (*pb.ListAppealsResponse, error) {
+	list, err := s.repo.ListAppeals(ctx, f)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list appeals: %v", err)
+	}
+	resp := &pb.ListAppealsResponse{Appeals: make([]*pb.Appeal, 0, len(list))}
+	for i := range list {
+		resp.Appeals = append(resp.Appeals, toPBAppeal(&list[i]))
+	}
+	return resp, nil
+}
+
+func (s *PolygonServer) DecideAppeal(ctx context.Context, req *pb.DecideAppealRequest) (*pb.Appeal, error) {
+	if strings.TrimSpace(req.GetAppealId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "appeal_id required")
+	}
+	id, err := uuid.Parse(req.GetAppealId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid appeal_id")
+	}
+	switch req.GetStatus() {
+	case pb.AppealStatus_APPEAL_STATUS_ACCEPTED, pb.AppealStatus_APPEAL_STATUS_DENIED:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "status must be ACCEPTED or DENIED")
+	}
+	deciderID, err := s.callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a, err := s.repo.DecideAppeal(ctx, id, int32(req.GetStatus()), deciderID, strings.TrimSpace(req.GetComment()))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrAppealTargetChanged):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, pgx.ErrNoRows):
+			if _, gerr := s.repo.GetAppeal(ctx, id); gerr == nil {
+				return nil, status.Error(codes.FailedPrecondition, "appeal already decided")
+			}
+			return nil, status.Error(codes.NotFound, "appeal not found")
+		}
+		return nil, status.Errorf(codes.Internal, "decide appeal: %v", err)
+	}
+	return toPBAppeal(a), nil
+}
+
+func toPBAppeal(a *storage.Appeal) *pb.Appeal {
+	res := &pb.Appeal{
+		Id:              a.ID.String(),
+		TeamId:          a.TeamID.String(),
+		EventId:         optionalUUIDString(a.EventID),
+		ReportId:        optionalUUIDString(a.ReportID),
+		FineId:          optionalUUIDString(a.FineID),
+		AuthorId:        a.AuthorID.String(),
+		Justification:   a.Justification,
+		Status:          pb.AppealStatus(a.Status),
+		DecisionComment: a.DecisionComment,
+		DecidedBy:       optionalUUIDString(a.DecidedBy),
+		CreatedAt:       a.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if a.DecidedAt != nil {
+		res.DecidedAt = a.DecidedAt.UTC().Format(time.RFC3339)
+	}
+	return res
+}
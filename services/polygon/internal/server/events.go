@@ -0,0 +1,426 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// callerEvent — текущее соревнование команды вызывающего; nil, если команды нет
+// или она не участвует в соревнованиях (тогда данные не ограничиваются). См. teamEvent.
+func (s *PolygonServer) callerEvent(ctx context.Context) (*storage.Event, error) {
+	_, teamIDStr, _ := s.extractAuth(ctx)
+	if teamIDStr == "" {
+		return nil, nil
+	}
+	tid, err := uuid.Parse(teamIDStr)
+	if err != nil {
+		return nil, nil
+	}
+	return s.teamEvent(ctx, tid)
+}
+
+// teamEvent — текущее соревнование команды. Если все соревнования команды архивированы,
+// возвращается closedEvent: данные ограничиваются пустым соревнованием, а не открываются целиком.
+func (s *PolygonServer) teamEvent(ctx context.Context, teamID uuid.UUID) (*storage.Event, error) {
+	ev, err := s.repo.GetTeamCurrentEvent(ctx, teamID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		has, err := s.repo.TeamHasEvents(ctx, teamID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "team events: %v", err)
+		}
+		if has {
+			return closedEvent(), nil
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "current event: %v", err)
+	}
+	return ev, nil
+}
+
+// closedEvent — соревнование-заглушка без полигонов и команд для команды, чьи соревнования
+// архивированы. Его id (uuid.Nil) не совпадает ни с одним соревнованием, поэтому выборки пусты.
+func closedEvent() *storage.Event {
+	archived := time.Unix(0, 0)
+	return &storage.Event{ArchivedAt: &archived}
+}
+
+// isClosedEvent — ev получено из closedEvent.
+func isClosedEvent(ev *storage.Event) bool {
+	return ev != nil && ev.ID == uuid.Nil
+}
+
+func eventIDOf(ev *storage.Event) *uuid.UUID {
+	if ev == nil {
+		return nil
+	}
+	return &ev.ID
+}
+
+// scoreScope — область подсчёта очков для клиента: соревнование eventID (любое, включая архивные;
+// если не задано — текущее соревнование команды вызывающего) и момент заморозки скорборда.
+func (s *PolygonServer) scoreScope(ctx context.Context, eventID string) (storage.ScoreScope, error) {
+	var scope storage.ScoreScope
+	cutoff, err := s.scoreCutoff(ctx)
+	if err != nil {
+		return scope, status.Errorf(codes.Internal, "scoreboard freeze: %v", err)
+	}
+	scope.Until = cutoff
+	if strings.TrimSpace(eventID) != "" {
+		id, err := uuid.Parse(eventID)
+		if err != nil {
+			return scope, status.Error(codes.InvalidArgument, "invalid event_id")
+		}
+		if _, err := s.repo.GetEvent(ctx, id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return scope, status.Error(codes.NotFound, "event not found")
+			}
+			return scope, status.Errorf(codes.Internal, "get event: %v", err)
+		}
+		scope.EventID = &id
+		return scope, nil
+	}
+	ev, err := s.callerEvent(ctx)
+	if err != nil {
+		return scope, err
+	}
+	scope.EventID = eventIDOf(ev)
+	return scope, nil
+}
+
+// eventTeamFilter — множество команд соревнования scope; nil — без ограничения.
+func (s *PolygonServer) eventTeamFilter(ctx context.Context, scope storage.ScoreScope) (map[uuid.UUID]bool, error) {
+	if scope.EventID == nil {
+		return nil, nil
+	}
+	if *scope.EventID == uuid.Nil {
+		return map[uuid.UUID]bool{}, nil
+	}
+	ev, err := s.repo.GetEvent(ctx, *scope.EventID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get event: %v", err)
+	}
+	res := make(map[uuid.UUID]bool, len(ev.TeamIDs))
+	for _, id := range ev.TeamIDs {
+		res[id] = true
+	}
+	return res, nil
+}
+
+// resolveEventID — явно указанное соревнование или текущее соревнование команды.
+func (s *PolygonServer) resolveEventID(ctx context.Context, eventID string, teamID uuid.UUID) (*uuid.UUID, error) {
+	if strings.TrimSpace(eventID) == "" {
+		ev, err := s.teamEvent(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+		if isClosedEvent(ev) {
+			return nil, nil
+		}
+		return eventIDOf(ev), nil
+	}
+	id, err := uuid.Parse(eventID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid event_id")
+	}
+	if _, err := s.repo.GetEvent(ctx, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "event not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get event: %v", err)
+	}
+	return &id, nil
+}
+
+func (s *PolygonServer) ListMyEvents(ctx context.Context, _ *emptypb.Empty) (*pb.ListEventsResponse, error) {
+	_, teamIDStr, _ := s.extractAuth(ctx)
+	if teamIDStr == "" {
+		return &pb.ListEventsResponse{}, nil
+	}
+	tid, err := uuid.Parse(teamIDStr)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid team id")
+	}
+	list, err := s.repo.ListEvents(ctx, &tid)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list events: %v", err)
+	}
+	return toPBEvents(list), nil
+}
+
+func (s *PolygonServer) GetCurrentEvent(ctx context.Context, _ *emptypb.Empty) (*pb.GetCurrentEventResponse, error) {
+	ev, err := s.callerEvent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ev == nil || isClosedEvent(ev) {
+		return &pb.GetCurrentEventResponse{}, nil
+	}
+	return &pb.GetCurrentEventResponse{Event: toPBEvent(ev)}, nil
+}
+
+func (s *PolygonServer) ListEvents(ctx context.Context, _ *emptypb.Empty) (*pb.ListEventsResponse, error) {
+	list, err := s.repo.ListEvents(ctx, nil)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list events: %v", err)
+	}
+	return toPBEvents(list), nil
+}
+
+func (s *PolygonServer) GetEvent(ctx context.Context, req *pb.EventRequest) (*pb.Event, error) {
+	if strings.TrimSpace(req.GetId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	return s.loadPBEvent(ctx, id)
+}
+
+func (s *PolygonServer) CreateEvent(ctx context.Context, req *pb.CreateEventRequest) (*pb.Event, error) {
+	if strings.TrimSpace(req.GetName()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+	startsAt, err := time.Parse(time.RFC3339, strings.TrimSpace(req.GetStartsAt()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid starts_at")
+	}
+	endsAt, err := time.Parse(time.RFC3339, strings.TrimSpace(req.GetEndsAt()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid ends_at")
+	}
+	if !endsAt.After(startsAt) {
+		return nil, status.Error(codes.InvalidArgument, "ends_at must be after starts_at")
+	}
+	ev := &storage.Event{ID: uuid.New(), Name: strings.TrimSpace(req.GetName()), Description: req.GetDescription(), StartsAt: startsAt, EndsAt: endsAt}
+	for _, v := range req.GetTeamIds() {
+		tid, err := uuid.Parse(v)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid team_ids")
+		}
+		ev.TeamIDs = append(ev.TeamIDs, tid)
+	}
+	for _, v := range req.GetPolygonIds() {
+		pid, err := uuid.Parse(v)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid polygon_ids")
+		}
+		ev.PolygonIDs = append(ev.PolygonIDs, pid)
+	}
+	if err := s.repo.CreateEvent(ctx, ev); err != nil {
+		return nil, status.Errorf(codes.Internal, "create event: %v", err)
+	}
+	return s.loadPBEvent(ctx, ev.ID)
+}
+
+func (s *PolygonServer) EditEvent(ctx context.Context, req *pb.EditEventRequest) (*pb.Event, error) {
+	if strings.TrimSpace(req.GetId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	cur, err := s.repo.GetEvent(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "event not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get event: %v", err)
+	}
+	var namePtr *string
+	if req.Name != nil {
+		v := strings.TrimSpace(req.GetName())
+		if v == "" {
+			return nil, status.Error(codes.InvalidArgument, "name required")
+		}
+		namePtr = &v
+	}
+	var startsPtr, endsPtr *time.Time
+	startsAt, endsAt := cur.StartsAt, cur.EndsAt
+	if req.StartsAt != nil {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(req.GetStartsAt()))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid starts_at")
+		}
+		startsPtr, startsAt = &t, t
+	}
+	if req.EndsAt != nil {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(req.GetEndsAt()))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid ends_at")
+		}
+		endsPtr, endsAt = &t, t
+	}
+	if !endsAt.After(startsAt) {
+		return nil, status.Error(codes.InvalidArgument, "ends_at must be after starts_at")
+	}
+	if err := s.repo.UpdateEvent(ctx, id, namePtr, req.Description, startsPtr, endsPtr, req.Archived); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "event not found")
+		}
+		return nil, status.Errorf(codes.Internal, "update event: %v", err)
+	}
+	return s.loadPBEvent(ctx, id)
+}
+
+func (s *PolygonServer) DeleteEvent(ctx context.Context, req *pb.EventRequest) (*emptypb.Empty, error) {
+	if strings.TrimSpace(req.GetId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	if err := s.repo.DeleteEvent(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, status.Error(codes.NotFound, "event not found")
+		case errors.Is(err, storage.ErrEventInUse):
+			return nil, status.Error(codes.FailedPrecondition, "event has reports or score entries, archive it instead")
+		}
+		return nil, status.Errorf(codes.Internal, "delete event: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *PolygonServer) AddEventTeam(ctx context.Context, req *pb.EventTeamRequest) (*pb.Event, error) {
+	eid, tid, err := s.parseEventMember(ctx, req.GetEventId(), req.GetTeamId(), "team_id")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetTeam(ctx, tid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "team not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get team: %v", err)
+	}
+	if err := s.repo.AddEventTeam(ctx, eid, tid); err != nil {
+		return nil, status.Errorf(codes.Internal, "add event team: %v", err)
+	}
+	return s.loadPBEvent(ctx, eid)
+}
+
+func (s *PolygonServer) RemoveEventTeam(ctx context.Context, req *pb.EventTeamRequest) (*pb.Event, error) {
+	eid, tid, err := s.parseEventMember(ctx, req.GetEventId(), req.GetTeamId(), "team_id")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RemoveEventTeam(ctx, eid, tid); err != nil {
+		return nil, status.Errorf(codes.Internal, "remove event team: %v", err)
+	}
+	return s.loadPBEvent(ctx, eid)
+}
+
+func (s *PolygonServer) AddEventPolygon(ctx context.Context, req *pb.EventPolygonRequest) (*pb.Event, error) {
+	eid, pid, err := s.parseEventMember(ctx, req.GetEventId(), req.GetPolygonId(), "polygon_id")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetPolygon(ctx, pid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "polygon not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get polygon: %v", err)
+	}
+	if err := s.repo.AddEventPolygon(ctx, eid, pid); err != nil {
+		return nil, status.Errorf(codes.Internal, "add event polygon: %v", err)
+	}
+	return s.loadPBEvent(ctx, eid)
+}
+
+func (s *PolygonServer) RemoveEventPolygon(ctx context.Context, req *pb.EventPolygonRequest) (*pb.Event, error) {
+	eid, pid, err := s.parseEventMember(ctx, req.GetEventId(), req.GetPolygonId(), "polygon_id")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RemoveEventPolygon(ctx, eid, pid); err != nil {
+		return nil, status.Errorf(codes.Internal, "remove event polygon: %v", err)
+	}
+	return s.loadPBEvent(ctx, eid)
+}
+
+// parseEventMember разбирает id соревнования и участника и проверяет, что соревнование существует.
+func (s *PolygonServer) parseEventMember(ctx context.Context, eventID, memberID, field string) (uuid.UUID, uuid.UUID, error) {
+	if strings.TrimSpace(eventID) == "" {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "event_id required")
+	}
+	if strings.TrimSpace(memberID) == "" {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, field+" required")
+	}
+	eid, err := uuid.Parse(eventID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "invalid event_id")
+	}
+	mid, err := uuid.Parse(memberID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "invalid "+field)
+	}
+	if _, err := s.repo.GetEvent(ctx, eid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, uuid.Nil, status.Error(codes.NotFound, "event not found")
+		}
+		return uuid.Nil, uuid.Nil, status.Errorf(codes.Internal, "get event: %v", err)
+	}
+	return eid, mid, nil
+}
+
+func (s *PolygonServer) loadPBEvent(ctx context.Context, id uuid.UUID) (*pb.Event, error) {
+	ev, err := s.repo.GetEvent(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "event not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get event: %v", err)
+	}
+	return toPBEvent(ev), nil
+}
+
+func toPBEvents(list []storage.Event) *pb.ListEventsResponse {
+	resp := &pb.ListEventsResponse{Events: make([]*pb.Event, 0, len(list))}
+	for i := range list {
+		resp.Events = append(resp.Events, toPBEvent(&list[i]))
+	}
+	return resp
+}
+
+func toPBEvent(ev *storage.Event) *pb.Event {
+	res := &pb.Event{
+		Id:          ev.ID.String(),
+		Name:        ev.Name,
+		Description: ev.Description,
+		StartsAt:    ev.StartsAt.UTC().Format(time.RFC3339),
+		EndsAt:      ev.EndsAt.UTC().Format(time.RFC3339),
+		Archived:    ev.ArchivedAt != nil,
+	}
+	if ev.ArchivedAt != nil {
+		res.ArchivedAt = ev.ArchivedAt.UTC().Format(time.RFC3339)
+	}
+	for _, id := range ev.TeamIDs {
+		res.TeamIds = append(res.TeamIds, id.String())
+	}
+	for _, id := range ev.PolygonIDs {
+		res.PolygonIds = append(res.PolygonIds, id.String())
+	}
+	return res
+}
+
+func optionalUUIDString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
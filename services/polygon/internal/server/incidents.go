@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -20,6 +21,46 @@ func validatePercent(v int64) (int, error) {
 	}
 	return int(v), nil
 }
+
+// redSolvers — число красных команд с принятым отчётом по каждому инциденту.
+func redSolvers(accepted []storage.AcceptedRedReportSummary) map[uuid.UUID]int {
+	teams := map[uuid.UUID]map[uuid.UUID]struct{}{}
+	for _, ar := range accepted {
+		if teams[ar.IncidentID] == nil {
+			teams[ar.IncidentID] = map[uuid.UUID]struct{}{}
+		}
+		teams[ar.IncidentID][ar.TeamID] = struct{}{}
+	}
+	res := make(map[uuid.UUID]int, len(teams))
+	for id, t := range teams {
+		res[id] = len(t)
+	}
+	return res
+}
+
+func validateIncidentScoring(sc storage.IncidentScoring, basePrize int64) error {
+	switch sc.Mode {
+	case storage.IncidentScoringFirstOnly, storage.IncidentScoringFixed:
+		return nil
+	case storage.IncidentScoringDynamic:
+		if sc.DynamicDecay <= 0 {
+			return errors.New("dynamic_decay must be positive")
+		}
+		if sc.DynamicMinimum < 0 || sc.DynamicMinimum > basePrize {
+			return errors.New("dynamic_minimum must be between 0 and red_prize")
+		}
+		return nil
+	}
+	return errors.New("invalid scoring_mode")
+}
+
+func validateIncidentBonuses(b storage.IncidentBonuses) error {
+	if b.FirstBlood < 0 || b.SecondBlood < 0 || b.ThirdBlood < 0 || b.BlueSpeed < 0 {
+		return errors.New("bonuses must be >= 0")
+	}
+	return nil
+}
+
 func (s *PolygonServer) CreateIncident(ctx context.Context, req *pb.CreateIncidentRequest) (*pb.Incident, error) {
 	if req.GetPolygonId() == "" {
 		return nil, status.Error(codes.InvalidArgument, "polygon_id required")
@@ -39,11 +80,26 @@ func (s *PolygonServer) CreateIncident(ctx context.Context, req *pb.CreateIncide
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "invalid blue_prize_procent")
 	}
+	scoring := storage.IncidentScoring{Mode: int32(req.GetScoringMode()), DynamicMinimum: req.GetDynamicMinimum(), DynamicDecay: req.GetDynamicDecay()}
+	if err := validateIncidentScoring(scoring, basePrize); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	bonuses := storage.IncidentBonuses{FirstBlood: req.GetFirstBloodBonus(), SecondBlood: req.GetSecondBloodBonus(), ThirdBlood: req.GetThirdBloodBonus(), BlueSpeed: req.GetBlueSpeedBonus()}
+	if err := validateIncidentBonuses(bonuses); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	review, err := parseReviewPolicy(req.GetReviewPolicy(), req.GetReviewJudges(), req.GetHeadJudgeId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	id := uuid.New()
-	if err := s.repo.CreateIncident(ctx, id, pid, strings.TrimSpace(req.GetName()), req.GetDescription(), basePrize, bluePct); err != nil {
+	if err := s.repo.CreateIncident(ctx, id, pid, strings.TrimSpace(req.GetName()), req.GetDescription(), basePrize, bluePct, scoring, bonuses, review); err != nil {
 		return nil, status.Errorf(codes.Internal, "create: %v", err)
 	}
-	return &pb.Incident{Id: id.String(), Name: req.GetName(), Description: req.GetDescription(), RedPrize: req.GetRedPrize(), BluePrizeProcent: req.GetBluePrizeProcent()}, nil
+	return &pb.Incident{Id: id.String(), Name: req.GetName(), Description: req.GetDescription(), RedPrize: req.GetRedPrize(), BluePrizeProcent: req.GetBluePrizeProcent(),
+		ScoringMode: req.GetScoringMode(), DynamicMinimum: scoring.DynamicMinimum, DynamicDecay: scoring.DynamicDecay, CurrentPrize: basePrize,
+		FirstBloodBonus: bonuses.FirstBlood, SecondBloodBonus: bonuses.SecondBlood, ThirdBloodBonus: bonuses.ThirdBlood, BlueSpeedBonus: bonuses.BlueSpeed,
+		ReviewPolicy: pb.ReviewPolicy(review.Mode), ReviewJudges: uint32(review.Judges), HeadJudgeId: optionalUUIDString(review.HeadJudgeID)}, nil
 }
 func (s *PolygonServer) EditIncident(ctx context.Context, req *pb.EditIncidentRequest) (*pb.Incident, error) {
 	if req.GetId() == "" {
@@ -68,20 +124,107 @@ func (s *PolygonServer) EditIncident(ctx context.Context, req *pb.EditIncidentRe
 		v := req.RedPrize
 		basePrizePtr = &v
 	}
-	if req.BluePrizeProcent > 0 { 
+	if req.BluePrizeProcent > 0 {
 		v, err := validatePercent(req.BluePrizeProcent)
 		if err != nil {
 			return nil, status.Error(codes.InvalidArgument, "invalid blue_prize_procent")
 		}
 		bluePctPtr = &v
 	}
-	if err := s.repo.UpdateIncident(ctx, id, namePtr, descPtr, basePrizePtr, bluePctPtr); err != nil {
+	var scoringPtr *storage.IncidentScoring
+	var bonusesPtr *storage.IncidentBonuses
+	var reviewPtr *storage.IncidentReviewPolicy
+	bonusesSet := req.FirstBloodBonus != nil || req.SecondBloodBonus != nil || req.ThirdBloodBonus != nil || req.BlueSpeedBonus != nil
+	reviewSet := req.ReviewPolicy != nil || req.ReviewJudges != nil || req.HeadJudgeId != nil
+	if req.ScoringMode != nil || req.DynamicMinimum != nil || req.DynamicDecay != nil || basePrizePtr != nil || bonusesSet || reviewSet {
+		cur, err := s.repo.GetIncident(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, status.Error(codes.NotFound, "incident not found")
+			}
+			return nil, status.Errorf(codes.Internal, "get: %v", err)
+		}
+		sc := cur.Scoring
+		if req.ScoringMode != nil {
+			sc.Mode = int32(req.GetScoringMode())
+		}
+		if req.DynamicMinimum != nil {
+			sc.DynamicMinimum = req.GetDynamicMinimum()
+		}
+		if req.DynamicDecay != nil {
+			sc.DynamicDecay = req.GetDynamicDecay()
+		}
+		base := cur.BasePrize
+		if basePrizePtr != nil {
+			base = *basePrizePtr
+		}
+		if err := validateIncidentScoring(sc, base); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if sc != cur.Scoring {
+			scoringPtr = &sc
+		}
+		b := cur.Bonuses
+		if req.FirstBloodBonus != nil {
+			b.FirstBlood = req.GetFirstBloodBonus()
+		}
+		if req.SecondBloodBonus != nil {
+			b.SecondBlood = req.GetSecondBloodBonus()
+		}
+		if req.ThirdBloodBonus != nil {
+			b.ThirdBlood = req.GetThirdBloodBonus()
+		}
+		if req.BlueSpeedBonus != nil {
+			b.BlueSpeed = req.GetBlueSpeedBonus()
+		}
+		if err := validateIncidentBonuses(b); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if b != cur.Bonuses {
+			bonusesPtr = &b
+		}
+		if reviewSet {
+			mode, judges, head := pb.ReviewPolicy(cur.Review.Mode), uint32(cur.Review.Judges), optionalUUIDString(cur.Review.HeadJudgeID)
+			if req.ReviewPolicy != nil {
+				mode = req.GetReviewPolicy()
+			}
+			if req.ReviewJudges != nil {
+				judges = req.GetReviewJudges()
+			}
+			if req.HeadJudgeId != nil {
+				head = req.GetHeadJudgeId()
+			}
+			rv, err := parseReviewPolicy(mode, judges, head)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			reviewPtr = &rv
+		}
+	}
+	if err := s.repo.UpdateIncident(ctx, id, namePtr, descPtr, basePrizePtr, bluePctPtr, scoringPtr, bonusesPtr, reviewPtr); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, status.Error(codes.NotFound, "incident not found")
 		}
 		return nil, status.Errorf(codes.Internal, "update: %v", err)
 	}
-	return &pb.Incident{Id: id.String(), Name: derefOr(namePtr, ""), Description: derefOr(descPtr, ""), RedPrize: req.GetRedPrize(), BluePrizeProcent: req.GetBluePrizeProcent()}, nil
+	res := &pb.Incident{Id: id.String(), Name: derefOr(namePtr, ""), Description: derefOr(descPtr, ""), RedPrize: req.GetRedPrize(), BluePrizeProcent: req.GetBluePrizeProcent()}
+	if scoringPtr != nil {
+		res.ScoringMode = pb.IncidentScoringMode(scoringPtr.Mode)
+		res.DynamicMinimum = scoringPtr.DynamicMinimum
+		res.DynamicDecay = scoringPtr.DynamicDecay
+	}
+	if bonusesPtr != nil {
+		res.FirstBloodBonus = bonusesPtr.FirstBlood
+		res.SecondBloodBonus = bonusesPtr.SecondBlood
+		res.ThirdBloodBonus = bonusesPtr.ThirdBlood
+		res.BlueSpeedBonus = bonusesPtr.BlueSpeed
+	}
+	if reviewPtr != nil {
+		res.ReviewPolicy = pb.ReviewPolicy(reviewPtr.Mode)
+		res.ReviewJudges = uint32(reviewPtr.Judges)
+		res.HeadJudgeId = optionalUUIDString(reviewPtr.HeadJudgeID)
+	}
+	return res, nil
 }
 func (s *PolygonServer) DeleteIncident(ctx context.Context, req *pb.DeleteIncidentRequest) (*emptypb.Empty, error) {
 	if req.GetId() == "" {
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -15,6 +16,20 @@ import (
 )
 
 func (s *PolygonServer) GetInitialItems(ctx context.Context, _ *emptypb.Empty) (*pb.GetInitialItemsResponse, error) {
+	list, err := s.callerInitialItems(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.GetInitialItemsResponse{}
+	for i := range list {
+		resp.InitialItems = append(resp.InitialItems, toPBInitialItem(&list[i]))
+	}
+	return resp, nil
+}
+
+// callerInitialItems — исходные материалы, видимые вызывающему: публичные, личные и его команд
+// в рамках текущего соревнования; rangeID != nil — материалы площадки, иначе общие.
+func (s *PolygonServer) callerInitialItems(ctx context.Context, rangeID *uuid.UUID) ([]storage.InitialItem, error) {
 	var userIDPtr *uuid.UUID
 	var teamIDs []uuid.UUID
 	if uid, _, err := s.extractAuth(ctx); err == nil && uid != "" {
@@ -29,23 +44,15 @@ func (s *PolygonServer) GetInitialItems(ctx context.Context, _ *emptypb.Empty) (
 			}
 		}
 	}
-	list, err := s.repo.ListInitialItems(ctx, userIDPtr, teamIDs)
+	ev, err := s.callerEvent(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "list initial: %v", err)
+		return nil, err
 	}
-	resp := &pb.GetInitialItemsResponse{}
-	for _, it := range list {
-		var uidStr string
-		if it.UserID != nil {
-			uidStr = it.UserID.String()
-		}
-		var tidStr string
-		if it.TeamID != nil {
-			tidStr = it.TeamID.String()
-		}
-		resp.InitialItems = append(resp.InitialItems, &pb.InitialItem{Id: it.ID.String(), Name: it.Name, Description: it.Description, FilesUrls: it.Files, UserId: uidStr, TeamId: tidStr})
+	list, err := s.repo.ListInitialItems(ctx, userIDPtr, teamIDs, eventIDOf(ev), rangeID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list initial: %v", err)
 	}
-	return resp, nil
+	return list, nil
 }
 
 func (s *PolygonServer) CreateInitialItem(ctx context.Context, req *pb.CreateInitialItemRequest) (*pb.InitialItem, error) {
@@ -70,7 +77,23 @@ func (s *PolygonServer) CreateInitialItem(ctx context.Context, req *pb.CreateIni
 			return nil, status.Error(codes.InvalidArgument, "invalid team_id")
 		}
 	}
-	if err := s.repo.CreateInitialItem(ctx, id, strings.TrimSpace(req.GetName()), req.GetDescription(), req.GetFilesUrls(), userIDPtr, teamIDPtr); err != nil {
+	var eventIDPtr *uuid.UUID
+	if req.GetEventId() != "" {
+		if e, err := uuid.Parse(req.GetEventId()); err == nil {
+			eventIDPtr = &e
+		} else {
+			return nil, status.Error(codes.InvalidArgument, "invalid event_id")
+		}
+	}
+	var rangeIDPtr *uuid.UUID
+	if req.GetRangeId() != "" {
+		if rid, err := uuid.Parse(req.GetRangeId()); err == nil {
+			rangeIDPtr = &rid
+		} else {
+			return nil, status.Error(codes.InvalidArgument, "invalid range_id")
+		}
+	}
+	if err := s.repo.CreateInitialItem(ctx, id, strings.TrimSpace(req.GetName()), req.GetDescription(), req.GetFilesUrls(), userIDPtr, teamIDPtr, eventIDPtr, rangeIDPtr); err != nil {
 		return nil, status.Errorf(codes.Internal, "create: %v", err)
 	}
 	var uidStr string
@@ -81,7 +104,7 @@ func (s *PolygonServer) CreateInitialItem(ctx context.Context, req *pb.CreateIni
 	if teamIDPtr != nil {
 		tidStr = teamIDPtr.String()
 	}
-	return &pb.InitialItem{Id: id.String(), Name: req.GetName(), Description: req.GetDescription(), FilesUrls: req.GetFilesUrls(), UserId: uidStr, TeamId: tidStr}, nil
+	return &pb.InitialItem{Id: id.String(), Name: req.GetName(), Description: req.GetDescription(), FilesUrls: req.GetFilesUrls(), UserId: uidStr, TeamId: tidStr, EventId: optionalUUIDString(eventIDPtr), RangeId: optionalUUIDString(rangeIDPtr)}, nil
 }
 func (s *PolygonServer) EditInitialItem(ctx context.Context, req *pb.EditInitialItemRequest) (*pb.InitialItem, error) {
 	if req.GetId() == "" {
@@ -129,7 +152,25 @@ func (s *PolygonServer) EditInitialItem(ctx context.Context, req *pb.EditInitial
 			}
 		}
 	}
-	if err := s.repo.UpdateInitialItem(ctx, iid, namePtr, descPtr, filesPtr, userSet, userPtr, teamSet, teamPtr); err != nil {
+	eventSet := req.EventId != nil
+	var eventPtr *uuid.UUID
+	if req.GetEventId() != "" { // пустая строка = общий для всех соревнований
+		if e, err := uuid.Parse(req.GetEventId()); err == nil {
+			eventPtr = &e
+		} else {
+			return nil, status.Error(codes.InvalidArgument, "invalid event_id")
+		}
+	}
+	rangeSet := req.RangeId != nil
+	var rangePtr *uuid.UUID
+	if req.GetRangeId() != "" { // пустая строка = общий материал
+		if rid, err := uuid.Parse(req.GetRangeId()); err == nil {
+			rangePtr = &rid
+		} else {
+			return nil, status.Error(codes.InvalidArgument, "invalid range_id")
+		}
+	}
+	if err := s.repo.UpdateInitialItem(ctx, iid, namePtr, descPtr, filesPtr, userSet, userPtr, teamSet, teamPtr, eventSet, eventPtr, rangeSet, rangePtr); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, status.Error(codes.NotFound, "initial item not found")
 		}
@@ -139,15 +180,7 @@ func (s *PolygonServer) EditInitialItem(ctx context.Context, req *pb.EditInitial
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "get: %v", err)
 	}
-	var uidStr string
-	if it.UserID != nil {
-		uidStr = it.UserID.String()
-	}
-	var tidStr string
-	if it.TeamID != nil {
-		tidStr = it.TeamID.String()
-	}
-	return &pb.InitialItem{Id: it.ID.String(), Name: it.Name, Description: it.Description, FilesUrls: it.Files, UserId: uidStr, TeamId: tidStr}, nil
+	return toPBInitialItem(it), nil
 }
 func (s *PolygonServer) DeleteInitialItem(ctx context.Context, req *pb.DeleteInitialItemRequest) (*emptypb.Empty, error) {
 	if req.GetId() == "" {
@@ -165,3 +198,8 @@ func (s *PolygonServer) DeleteInitialItem(ctx context.Context, req *pb.DeleteIni
 	}
 	return &emptypb.Empty{}, nil
 }
+
+func toPBInitialItem(it *storage.InitialItem) *pb.InitialItem {
+	return &pb.InitialItem{Id: it.ID.String(), Name: it.Name, Description: it.Description, FilesUrls: it.Files,
+		UserId: optionalUUIDString(it.UserID), TeamId: optionalUUIDString(it.TeamID), EventId: optionalUUIDString(it.EventID), RangeId: optionalUUIDString(it.RangeID)}
+}
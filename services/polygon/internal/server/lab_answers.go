@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	labv1 "gis/polygon/api/lab/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *PolygonServer) SubmitStepAnswer(ctx context.Context, req *labv1.SubmitStepAnswerRequest) (*labv1.SubmitStepAnswerResponse, error) {
+	if req.GetPolygonId() == "" || req.GetStepId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "polygon_id and step_id required")
+	}
+	polygonID, err := uuid.Parse(req.GetPolygonId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid polygon_id")
+	}
+	stepID, err := uuid.Parse(req.GetStepId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid step_id")
+	}
+	if strings.TrimSpace(req.GetAnswer()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "answer required")
+	}
+
+	userID, teamRef, err := s.labParticipant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lab, err := s.repo.GetLabByPolygon(ctx, polygonID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "lab not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get lab: %v", err)
+	}
+	switch state, _, _ := labWindow(lab, time.Now()); state {
+	case labv1.LabState_LAB_STATE_NOT_STARTED:
+		return nil, status.Error(codes.FailedPrecondition, "lab not started")
+	case labv1.LabState_LAB_STATE_PAUSED:
+		return nil, status.Error(codes.FailedPrecondition, "lab paused")
+	case labv1.LabState_LAB_STATE_EXPIRED:
+		return nil, status.Error(codes.FailedPrecondition, "lab expired")
+	}
+	step, err := s.repo.GetLabStep(ctx, stepID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "step not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get step: %v", err)
+	}
+	if step.LabID != lab.ID {
+		return nil, status.Error(codes.NotFound, "step not found")
+	}
+	if !step.HasAnswer {
+		return nil, status.Error(codes.FailedPrecondition, "step does not accept answers")
+	}
+	if lab.Sequential {
+		steps, err := s.repo.ListLabStepsPublic(ctx, lab.ID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list steps: %v", err)
+		}
+		progress, err := s.participantProgress(ctx, lab.ID, userID, teamRef)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "progress: %v", err)
+		}
+		unlocked := false
+		for _, st := range unlockedSteps(lab, steps, progress) {
+			if st.ID == step.ID {
+				unlocked = true
+				break
+			}
+		}
+		if !unlocked {
+			return nil, status.Error(codes.FailedPrecondition, "step locked")
+		}
+	}
+
+	correct, err := matchStepAnswer(step.Answer, req.GetAnswer())
+	if err != nil {
+		// Текст ошибки может содержать ожидаемое значение — наружу его не отдаём.
+		return nil, status.Error(codes.Internal, "step answer misconfigured")
+	}
+
+	attempt := &storage.LabStepAttempt{
+		ID:        uuid.New(),
+		LabID:     lab.ID,
+		StepID:    step.ID,
+		UserID:    userID,
+		TeamID:    teamRef,
+		Answer:    req.GetAnswer(),
+		Correct:   correct,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateLabStepAttempt(ctx, attempt); err != nil {
+		return nil, status.Errorf(codes.Internal, "save attempt: %v", err)
+	}
+	attempts, err := s.repo.CountLabStepAttempts(ctx, step.ID, userID, teamRef)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "count attempts: %v", err)
+	}
+
+	return &labv1.SubmitStepAnswerResponse{
+		Correct:  correct,
+		Attempts: attempts,
+	}, nil
+}
+
+// stepAnswerSpec — формат LabStep.answer, см. комментарий к SubmitStepAnswerRequest.
+type stepAnswerSpec struct {
+	Mode   string        `json:"mode"`
+	Value  interface{}   `json:"value"`
+	Values []interface{} `json:"values"`
+}
+
+// matchStepAnswer сравнивает ответ участника с ожидаемым по правилам из answer JSON.
+func matchStepAnswer(expected json.RawMessage, submitted string) (bool, error) {
+	var spec stepAnswerSpec
+	if err := json.Unmarshal(expected, &spec); err != nil {
+		return false, err
+	}
+	got := strings.TrimSpace(submitted)
+
+	candidates := make([]string, 0, len(spec.Values)+1)
+	if spec.Value != nil {
+		candidates = append(candidates, answerString(spec.Value))
+	}
+	for _, v := range spec.Values {
+		candidates = append(candidates, answerString(v))
+	}
+	if len(candidates) == 0 {
+		return false, errors.New("answer has no value")
+	}
+
+	switch spec.Mode {
+	case "", "exact":
+		for _, c := range candidates {
+			if got == c {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "case_insensitive":
+		for _, c := range candidates {
+			if strings.EqualFold(got, c) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "regex":
+		for _, c := range candidates {
+			re, err := regexp.Compile(`^(?:` + c + `)$`)
+			if err != nil {
+				return false, err
+			}
+			if re.MatchString(got) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "set":
+		return equalAnswerSets(candidates, splitAnswerSet(got)), nil
+	}
+	return false, fmt.Errorf("unknown answer mode %q", spec.Mode)
+}
+
+func answerString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
+
+func splitAnswerSet(s string) []string {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == '\n' })
+	res := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func equalAnswerSets(expected, got []string) bool {
+	norm := func(in []string) []string {
+		seen := map[string]bool{}
+		out := make([]string, 0, len(in))
+		for _, v := range in {
+			v = strings.ToLower(v)
+			if !seen[v] {
+				seen[v] = true
+				out = append(out, v)
+			}
+		}
+		sort.Strings(out)
+		return out
+	}
+	a, b := norm(expected), norm(got)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
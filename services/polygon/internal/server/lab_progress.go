@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	labv1 "gis/polygon/api/lab/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *PolygonServer) GetLabProgress(ctx context.Context, req *labv1.GetLabProgressRequest) (*labv1.LabProgress, error) {
+	if req.GetPolygonId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "polygon_id required")
+	}
+	polygonID, err := uuid.Parse(req.GetPolygonId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid polygon_id")
+	}
+	userID, teamRef, err := s.labParticipant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lab, err := s.repo.GetLabByPolygon(ctx, polygonID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "lab not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get lab: %v", err)
+	}
+	steps, err := s.repo.ListLabStepsPublic(ctx, lab.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list steps: %v", err)
+	}
+	progress, err := s.participantProgress(ctx, lab.ID, userID, teamRef)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "progress: %v", err)
+	}
+
+	res := buildLabProgress(lab.ID, steps, progress)
+	if teamRef != nil {
+		res.TeamId = teamRef.String()
+	} else {
+		res.UserId = userID.String()
+	}
+	return res, nil
+}
+
+func (s *PolygonServer) ListLabProgress(ctx context.Context, req *labv1.ListLabProgressRequest) (*labv1.ListLabProgressResponse, error) {
+	if req.GetLabId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "lab_id required")
+	}
+	labID, err := uuid.Parse(req.GetLabId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid lab_id")
+	}
+	if _, err := s.repo.GetLab(ctx, labID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "lab not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get lab: %v", err)
+	}
+	steps, err := s.repo.ListLabStepsPublic(ctx, labID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list steps: %v", err)
+	}
+	rows, err := s.repo.ListLabStepProgress(ctx, labID, req.GetByUser(), nil)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "progress: %v", err)
+	}
+
+	var owners []uuid.UUID
+	byOwner := map[uuid.UUID]map[uuid.UUID]storage.LabStepProgress{}
+	for _, p := range rows {
+		m, ok := byOwner[p.OwnerID]
+		if !ok {
+			m = map[uuid.UUID]storage.LabStepProgress{}
+			byOwner[p.OwnerID] = m
+			owners = append(owners, p.OwnerID)
+		}
+		m[p.StepID] = p
+	}
+
+	res := make([]*labv1.LabProgress, 0, len(owners))
+	for _, owner := range owners {
+		lp := buildLabProgress(labID, steps, byOwner[owner])
+		if req.GetByUser() {
+			lp.UserId = owner.String()
+		} else {
+			lp.TeamId = owner.String()
+		}
+		res = append(res, lp)
+	}
+	return &labv1.ListLabProgressResponse{Progress: res}, nil
+}
+
+// labParticipant достаёт пользователя и (если есть) его команду из метаданных запроса.
+func (s *PolygonServer) labParticipant(ctx context.Context) (uuid.UUID, *uuid.UUID, error) {
+	uid, teamID, err := s.extractAuth(ctx)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	userID, err := uuid.Parse(uid)
+	if err != nil {
+		return uuid.Nil, nil, status.Error(codes.Unauthenticated, "invalid user id")
+	}
+	if teamID == "" {
+		return userID, nil, nil
+	}
+	tid, err := uuid.Parse(teamID)
+	if err != nil {
+		return uuid.Nil, nil, status.Error(codes.Unauthenticated, "invalid team id")
+	}
+	return userID, &tid, nil
+}
+
+// participantProgress — прогресс команды участника, либо личный, если он не в команде.
+func (s *PolygonServer) participantProgress(ctx context.Context, labID, userID uuid.UUID, teamRef *uuid.UUID) (map[uuid.UUID]storage.LabStepProgress, error) {
+	owner, byUser := userID, true
+	if teamRef != nil {
+		owner, byUser = *teamRef, false
+	}
+	rows, err := s.repo.ListLabStepProgress(ctx, labID, byUser, &owner)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[uuid.UUID]storage.LabStepProgress, len(rows))
+	for _, p := range rows {
+		res[p.StepID] = p
+	}
+	return res, nil
+}
+
+// unlockedSteps оставляет шаги, доступные участнику. В последовательном режиме открыты
+// все решённые шаги и первый нерешённый; шаги без ответа прохождение не блокируют.
+func unlockedSteps(lab *storage.Lab, steps []storage.LabStep, progress map[uuid.UUID]storage.LabStepProgress) []storage.LabStep {
+	if !lab.Sequential {
+		return steps
+	}
+	res := make([]storage.LabStep, 0, len(steps))
+	for _, st := range steps {
+		res = append(res, st)
+		if st.HasAnswer && progress[st.ID].SolvedAt == nil {
+			break
+		}
+	}
+	return res
+}
+
+func buildLabProgress(labID uuid.UUID, steps []storage.LabStep, progress map[uuid.UUID]storage.LabStepProgress) *labv1.LabProgress {
+	res := &labv1.LabProgress{
+		LabId:      labID.String(),
+		TotalSteps: int32(len(steps)),
+		Steps:      make([]*labv1.LabStepProgress, 0, len(steps)),
+	}
+	for _, st := range steps {
+		sp := &labv1.LabStepProgress{
+			StepId:     st.ID.String(),
+			OrderIndex: st.OrderIndex,
+		}
+		if p, ok := progress[st.ID]; ok {
+			sp.Attempts = p.Attempts
+			if p.SolvedAt != nil {
+				sp.Solved = true
+				sp.SolvedAt = timestamppb.New(*p.SolvedAt)
+				res.SolvedCount++
+			}
+			if p.SolvedBy != nil {
+				sp.SolvedBy = p.SolvedBy.String()
+			}
+		}
+		res.Steps = append(res.Steps, sp)
+	}
+	return res
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	externalv1 "gis/polygon/api/external/v1"
+	labv1 "gis/polygon/api/lab/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// runLabProvisioner периодически запускает развёртывание инфраструктуры для стартовавших лаб
+// и её удаление для лаб с истёкшим TTL. Запущенные job external_controller сохраняются на лабе,
+// поэтому повторного запуска не будет и после рестарта сервиса. Неудачный запуск развёртывания или
+// удаления повторяется с экспоненциальной задержкой, но не больше storage.LabProvisionMaxAttempts раз.
+func (s *PolygonServer) runLabProvisioner(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		s.provisionLabs(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *PolygonServer) provisionLabs(ctx context.Context, interval time.Duration) {
+	now := time.Now()
+
+	labs, err := s.repo.ListLabsToProvision(ctx, now)
+	if err != nil {
+		log.Printf("lab provisioner: list labs to provision: %v", err)
+	}
+	for i := range labs {
+		lab := &labs[i]
+		job, err := s.runLabRecipe(ctx, &lab.Provisioning, false)
+		if err != nil {
+			log.Printf("lab provisioner: provision lab %s: %v", lab.ID, err)
+			attempts, ferr := s.repo.FailLabProvision(ctx, lab.ID, interval)
+			if ferr != nil {
+				log.Printf("lab provisioner: save provision failure for lab %s: %v", lab.ID, ferr)
+			} else if attempts >= storage.LabProvisionMaxAttempts {
+				log.Printf("lab provisioner: lab %s: giving up after %d attempts", lab.ID, attempts)
+			}
+			continue
+		}
+		if err := s.repo.SetLabProvisionJob(ctx, lab.ID, job.GetId()); err != nil {
+			log.Printf("lab provisioner: save provision job for lab %s: %v", lab.ID, err)
+			continue
+		}
+		log.Printf("lab provisioner: lab %s provisioning started, job %s", lab.ID, job.GetId())
+	}
+
+	labs, err = s.repo.ListLabsToTeardown(ctx, now)
+	if err != nil {
+		log.Printf("lab provisioner: list labs to teardown: %v", err)
+	}
+	for i := range labs {
+		lab := &labs[i]
+		job, err := s.runLabRecipe(ctx, &lab.Provisioning, true)
+		if err != nil {
+			log.Printf("lab provisioner: teardown lab %s: %v", lab.ID, err)
+			attempts, ferr := s.repo.FailLabTeardown(ctx, lab.ID, interval)
+			if ferr != nil {
+				log.Printf("lab provisioner: save teardown failure for lab %s: %v", lab.ID, ferr)
+			} else if attempts >= storage.LabProvisionMaxAttempts {
+				log.Printf("lab provisioner: lab %s: teardown failed, giving up after %d attempts", lab.ID, attempts)
+			}
+			continue
+		}
+		if err := s.repo.SetLabTeardownJob(ctx, lab.ID, job.GetId()); err != nil {
+			log.Printf("lab provisioner: save teardown job for lab %s: %v", lab.ID, err)
+			continue
+		}
+		log.Printf("lab provisioner: lab %s teardown started, job %s", lab.ID, job.GetId())
+	}
+}
+
+// runLabRecipe запускает рецепт лабы через external_controller: provision при teardown=false,
+// удаление инфраструктуры при teardown=true.
+func (s *PolygonServer) runLabRecipe(ctx context.Context, p *storage.LabProvisioning, teardown bool) (*externalv1.Job, error) {
+	if s.externalClient == nil {
+		return nil, errors.New("external controller not configured")
+	}
+
+	var params *structpb.Struct
+	if len(p.Params) > 0 {
+		var m map[string]interface{}
+		if err := json.Unmarshal(p.Params, &m); err != nil {
+			return nil, err
+		}
+		st, err := structpb.NewStruct(m)
+		if err != nil {
+			return nil, err
+		}
+		params = st
+	}
+
+	switch p.Type {
+	case storage.LabProvisionTerraform:
+		action := "apply"
+		if teardown {
+			action = "destroy"
+		}
+		return s.externalClient.RunTerraform(ctx, &externalv1.RunTerraformRequest{
+			Workspace: p.TerraformWorkspace,
+			Action:    action,
+			Vars:      params,
+		})
+	case storage.LabProvisionAnsible:
+		templateID := p.SemaphoreTemplateID
+		if teardown {
+			templateID = p.SemaphoreTeardownTemplateID
+		}
+		return s.externalClient.RunAnsible(ctx, &externalv1.RunAnsibleRequest{
+			ProjectId:  p.SemaphoreProjectID,
+			TemplateId: templateID,
+			ExtraVars:  params,
+		})
+	case storage.LabProvisionJenkins:
+		job := p.JenkinsJob
+		if teardown {
+			job = p.JenkinsTeardownJob
+		}
+		return s.externalClient.RunJenkinsJob(ctx, &externalv1.RunJenkinsJobRequest{
+			JobName: job,
+			Params:  params,
+		})
+	}
+	return nil, errors.New("lab has no provisioning recipe")
+}
+
+// labProvisioningFromProto проверяет рецепт и переводит его в формат хранилища.
+func labProvisioningFromProto(p *labv1.LabProvisioning) (*storage.LabProvisioning, error) {
+	res := &storage.LabProvisioning{Type: int32(p.GetType())}
+	switch p.GetType() {
+	case labv1.LabProvisionType_LAB_PROVISION_TYPE_NONE:
+		return res, nil
+	case labv1.LabProvisionType_LAB_PROVISION_TYPE_TERRAFORM:
+		if p.GetTerraformWorkspace() == "" {
+			return nil, errors.New("terraform_workspace required")
+		}
+		res.TerraformWorkspace = p.GetTerraformWorkspace()
+	case labv1.LabProvisionType_LAB_PROVISION_TYPE_ANSIBLE:
+		if p.GetSemaphoreProjectId() == 0 || p.GetSemaphoreTemplateId() == 0 {
+			return nil, errors.New("semaphore_project_id and semaphore_template_id required")
+		}
+		res.SemaphoreProjectID = p.GetSemaphoreProjectId()
+		res.SemaphoreTemplateID = p.GetSemaphoreTemplateId()
+		res.SemaphoreTeardownTemplateID = p.GetSemaphoreTeardownTemplateId()
+	case labv1.LabProvisionType_LAB_PROVISION_TYPE_JENKINS:
+		if p.GetJenkinsJob() == "" {
+			return nil, errors.New("jenkins_job required")
+		}
+		res.JenkinsJob = p.GetJenkinsJob()
+		res.JenkinsTeardownJob = p.GetJenkinsTeardownJob()
+	default:
+		return nil, errors.New("invalid provisioning type")
+	}
+	if p.GetParams() != nil {
+		data, err := json.Marshal(p.GetParams().AsMap())
+		if err != nil {
+			return nil, err
+		}
+		res.Params = data
+	}
+	return res, nil
+}
+
+func labProvisioningToProto(p *storage.LabProvisioning) *labv1.LabProvisioning {
+	if p.Type == storage.LabProvisionNone {
+		return nil
+	}
+	pb := &labv1.LabProvisioning{
+		Type:                        labv1.LabProvisionType(p.Type),
+		TerraformWorkspace:          p.TerraformWorkspace,
+		SemaphoreProjectId:          p.SemaphoreProjectID,
+		SemaphoreTemplateId:         p.SemaphoreTemplateID,
+		SemaphoreTeardownTemplateId: p.SemaphoreTeardownTemplateID,
+		JenkinsJob:                  p.JenkinsJob,
+		JenkinsTeardownJob:          p.JenkinsTeardownJob,
+	}
+	if len(p.Params) > 0 {
+		var m map[string]interface{}
+		if json.Unmarshal(p.Params, &m) == nil {
+			pb.Params, _ = structpb.NewStruct(m)
+		}
+	}
+	return pb
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	labv1 "gis/polygon/api/lab/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// labWindow вычисляет состояние лабы на момент now. endsAt учитывает накопленные паузы,
+// а для приостановленной лабы — и текущую паузу (таймер заморожен). ttl_seconds = 0 — без ограничения.
+func labWindow(lab *storage.Lab, now time.Time) (state labv1.LabState, endsAt *time.Time, remaining int64) {
+	if lab.StartedAt == nil || now.Before(*lab.StartedAt) {
+		return labv1.LabState_LAB_STATE_NOT_STARTED, nil, 0
+	}
+	if lab.TTLSeconds > 0 {
+		// Момент окончания без учёта текущей паузы; пока лаба на паузе, время «стоит» на paused_at.
+		end := lab.StartedAt.Add(time.Duration(lab.TTLSeconds+lab.PausedSeconds) * time.Second)
+		ref := now
+		if lab.PausedAt != nil {
+			ref = *lab.PausedAt
+		}
+		if !ref.Before(end) {
+			return labv1.LabState_LAB_STATE_EXPIRED, &end, 0
+		}
+		remaining = int64(end.Sub(ref).Seconds())
+		end = now.Add(end.Sub(ref))
+		endsAt = &end
+	}
+	if lab.PausedAt != nil {
+		return labv1.LabState_LAB_STATE_PAUSED, endsAt, remaining
+	}
+	return labv1.LabState_LAB_STATE_RUNNING, endsAt, remaining
+}
+
+func (s *PolygonServer) StartLab(ctx context.Context, req *labv1.StartLabRequest) (*labv1.Lab, error) {
+	lab, err := s.loadLabForAdmin(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	state, _, _ := labWindow(lab, now)
+	switch state {
+	case labv1.LabState_LAB_STATE_RUNNING:
+		return nil, status.Error(codes.FailedPrecondition, "lab already running")
+	case labv1.LabState_LAB_STATE_PAUSED:
+		err = s.repo.ResumeLab(ctx, lab.ID, now)
+	default:
+		err = s.repo.StartLab(ctx, lab.ID, now)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "start lab: %v", err)
+	}
+	return s.reloadLab(ctx, lab.ID)
+}
+
+func (s *PolygonServer) PauseLab(ctx context.Context, req *labv1.PauseLabRequest) (*labv1.Lab, error) {
+	lab, err := s.loadLabForAdmin(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if state, _, _ := labWindow(lab, now); state != labv1.LabState_LAB_STATE_RUNNING {
+		return nil, status.Error(codes.FailedPrecondition, "lab is not running")
+	}
+	if err := s.repo.PauseLab(ctx, lab.ID, now); err != nil {
+		return nil, status.Errorf(codes.Internal, "pause lab: %v", err)
+	}
+	return s.reloadLab(ctx, lab.ID)
+}
+
+func (s *PolygonServer) ExtendLab(ctx context.Context, req *labv1.ExtendLabRequest) (*labv1.Lab, error) {
+	if req.GetSeconds() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "seconds must be positive")
+	}
+	lab, err := s.loadLabForAdmin(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if lab.TTLSeconds == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "lab has no ttl")
+	}
+	// После удаления инфраструктуры продлевать нечего: лабу нужно перезапустить, чтобы развернуть её заново.
+	if lab.TeardownJobID != "" {
+		return nil, status.Error(codes.FailedPrecondition, "lab infrastructure already torn down, restart the lab")
+	}
+	if err := s.repo.ExtendLab(ctx, lab.ID, req.GetSeconds()); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.FailedPrecondition, "lab infrastructure already torn down, restart the lab")
+		}
+		return nil, status.Errorf(codes.Internal, "extend lab: %v", err)
+	}
+	return s.reloadLab(ctx, lab.ID)
+}
+
+func (s *PolygonServer) loadLabForAdmin(ctx context.Context, id string) (*storage.Lab, error) {
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	labID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	lab, err := s.repo.GetLab(ctx, labID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "lab not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get lab: %v", err)
+	}
+	return lab, nil
+}
+
+func (s *PolygonServer) reloadLab(ctx context.Context, id uuid.UUID) (*labv1.Lab, error) {
+	lab, err := s.repo.GetLab(ctx, id)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get lab: %v", err)
+	}
+	return labToProto(lab), nil
+}
@@ -51,11 +51,27 @@ func (s *PolygonServer) GetLabSteps(ctx context.Context, req *labv1.GetLabStepsR
 		return nil, status.Error(codes.NotFound, "lab not found")
 	}
 
+	if state, _, _ := labWindow(lab, time.Now()); state == labv1.LabState_LAB_STATE_NOT_STARTED {
+		return nil, status.Error(codes.FailedPrecondition, "lab not started")
+	}
+
 	steps, err := s.repo.ListLabStepsPublic(ctx, lab.ID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "list steps: %v", err)
 	}
 
+	if lab.Sequential {
+		userID, teamRef, err := s.labParticipant(ctx)
+		if err != nil {
+			return nil, err
+		}
+		progress, err := s.participantProgress(ctx, lab.ID, userID, teamRef)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "progress: %v", err)
+		}
+		steps = unlockedSteps(lab, steps, progress)
+	}
+
 	protoSteps := make([]*labv1.LabStepPublic, len(steps))
 	for i, step := range steps {
 		protoSteps[i] = labStepToPublicProto(&step)
@@ -136,6 +152,15 @@ func (s *PolygonServer) CreateLab(ctx context.Context, req *labv1.CreateLabReque
 		startedAt = &t
 	}
 
+	var provisioning storage.LabProvisioning
+	if req.GetProvisioning() != nil {
+		p, err := labProvisioningFromProto(req.GetProvisioning())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		provisioning = *p
+	}
+
 	lab := &storage.Lab{
 		ID:          uuid.New(),
 		PolygonID:   polygonID,
@@ -146,6 +171,9 @@ func (s *PolygonServer) CreateLab(ctx context.Context, req *labv1.CreateLabReque
 		GroupID:     groupID,
 		StepCount:   0,
 		CreatedAt:   time.Now(),
+
+		Provisioning: provisioning,
+		Sequential:   req.GetSequential(),
 	}
 
 	if err := s.repo.CreateLab(ctx, lab); err != nil {
@@ -193,7 +221,15 @@ func (s *PolygonServer) UpdateLab(ctx context.Context, req *labv1.UpdateLabReque
 		groupID = &gid
 	}
 
-	if err := s.repo.UpdateLab(ctx, id, title, description, startedAt, ttlSeconds, groupID); err != nil {
+	var provisioning *storage.LabProvisioning
+	if req.GetProvisioning() != nil {
+		provisioning, err = labProvisioningFromProto(req.GetProvisioning())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	if err := s.repo.UpdateLab(ctx, id, title, description, startedAt, ttlSeconds, groupID, provisioning, req.Sequential); err != nil {
 		return nil, status.Errorf(codes.Internal, "update lab: %v", err)
 	}
 
@@ -222,7 +258,7 @@ func (s *PolygonServer) DeleteLab(ctx context.Context, req *labv1.DeleteLabReque
 	return &emptypb.Empty{}, nil
 }
 
-func (s *PolygonServer) ListLabStepsAdmin(ctx context.Context, req *labv1.ListLabStepsRequest) (*labv1.ListLabStepsResponse, error) {
+func (s *PolygonServer) ListLabSteps(ctx context.Context, req *labv1.ListLabStepsRequest) (*labv1.ListLabStepsResponse, error) {
 	if req.GetLabId() == "" {
 		return nil, status.Error(codes.InvalidArgument, "lab_id required")
 	}
@@ -363,6 +399,11 @@ func labToProto(lab *storage.Lab) *labv1.Lab {
 		TtlSeconds:  lab.TTLSeconds,
 		StepCount:   lab.StepCount,
 		CreatedAt:   timestamppb.New(lab.CreatedAt),
+
+		Provisioning:   labProvisioningToProto(&lab.Provisioning),
+		ProvisionJobId: lab.ProvisionJobID,
+		TeardownJobId:  lab.TeardownJobID,
+		Sequential:     lab.Sequential,
 	}
 	if lab.StartedAt != nil {
 		pb.StartedAt = timestamppb.New(*lab.StartedAt)
@@ -370,6 +411,15 @@ func labToProto(lab *storage.Lab) *labv1.Lab {
 	if lab.GroupID != nil {
 		pb.GroupId = lab.GroupID.String()
 	}
+	if lab.PausedAt != nil {
+		pb.PausedAt = timestamppb.New(*lab.PausedAt)
+	}
+	state, endsAt, remaining := labWindow(lab, time.Now())
+	pb.State = state
+	pb.RemainingSeconds = remaining
+	if endsAt != nil {
+		pb.EndsAt = timestamppb.New(*endsAt)
+	}
 	return pb
 }
 
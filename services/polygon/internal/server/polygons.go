@@ -34,31 +34,37 @@ func (s *PolygonServer) GetRedPolygons(ctx context.Context, _ *emptypb.Empty) (*
 			}
 		}
 	}
+	ev, err := s.callerEvent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ev != nil {
+		inEvent := polys[:0]
+		for _, p := range polys {
+			if ev.HasPolygon(p.ID) {
+				inEvent = append(inEvent, p)
+			}
+		}
+		polys = inEvent
+	}
+	eventID := eventIDOf(ev)
 	var allIncidentIDs []uuid.UUID
 	for _, p := range polys {
 		for _, in := range p.Incidents {
 			allIncidentIDs = append(allIncidentIDs, in.ID)
 		}
 	}
-	myStatuses := map[uuid.UUID]struct {
-		id     string
-		st     pb.ReportStatus
-		reason string
-	}{}
+	myStatuses := map[uuid.UUID]*storage.ReportMeta{}
 	if teamIDStr != "" {
 		if tid, err := uuid.Parse(teamIDStr); err == nil {
 			for _, incID := range allIncidentIDs {
-				if rid, st, reason, err := s.repo.GetLatestReportMetaForTeam(ctx, incID, tid); err == nil {
-					myStatuses[incID] = struct {
-						id     string
-						st     pb.ReportStatus
-						reason string
-					}{rid.String(), pb.ReportStatus(st), derefOr(reason, "")}
+				if ms, err := s.repo.GetLatestReportMetaForTeam(ctx, incID, tid, eventID); err == nil {
+					myStatuses[incID] = ms
 				}
 			}
 		}
 	}
-	acceptedList, err := s.repo.ListAcceptedRedReports(ctx, allIncidentIDs)
+	acceptedList, err := s.repo.ListAcceptedRedReports(ctx, allIncidentIDs, eventID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "accepted red reports: %v", err)
 	}
@@ -66,13 +72,33 @@ func (s *PolygonServer) GetRedPolygons(ctx context.Context, _ *emptypb.Empty) (*
 	for _, ar := range acceptedList {
 		acceptedMap[ar.IncidentID] = true
 	}
+	solvers := redSolvers(acceptedList)
+	myBonuses := map[uuid.UUID]int64{}
+	myUnread := map[uuid.UUID]int32{}
+	if tid, err := uuid.Parse(teamIDStr); err == nil {
+		cutoff, err := s.scoreCutoff(ctx)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "scoreboard freeze: %v", err)
+		}
+		if myBonuses, err = s.repo.ListTeamIncidentBonuses(ctx, tid, storage.ScoreScope{EventID: eventID, Until: cutoff}); err != nil {
+			return nil, status.Errorf(codes.Internal, "team bonuses: %v", err)
+		}
+		if myUnread, err = s.myUnreadComments(ctx, tid, myStatuses); err != nil {
+			return nil, err
+		}
+	}
 	out := &pb.GetRedPolygonsResponse{}
 	for _, p := range polys {
+		blueTeams, err := s.polygonBlueTeams(ctx, p.ID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "polygon blue teams: %v", err)
+		}
 		pv := &pb.PolygonRedView{
 			Id:          p.ID.String(),
 			Name:        p.Name,
 			Description: p.Description,
 			CoverUrl:    p.CoverURL,
+			BlueTeams:   blueTeams,
 		}
 		for _, in := range p.Incidents {
 			iv := &pb.IncidentRedView{
@@ -90,11 +116,19 @@ func (s *PolygonServer) GetRedPolygons(ctx context.Context, _ *emptypb.Empty) (*
 			if in.BlueSharePercent > 0 {
 				iv.BluePrizeProcent = int64(in.BlueSharePercent)
 			}
+			iv.ScoringMode = pb.IncidentScoringMode(in.Scoring.Mode)
+			iv.CurrentPrize = in.Scoring.Value(in.BasePrize, solvers[in.ID])
+			iv.FirstBloodBonus = in.Bonuses.FirstBlood
+			iv.SecondBloodBonus = in.Bonuses.SecondBlood
+			iv.ThirdBloodBonus = in.Bonuses.ThirdBlood
+			iv.MyBonus = myBonuses[in.ID]
 			if ms, ok := myStatuses[in.ID]; ok {
-				iv.MyReportStatus = ms.st
-				iv.MyReportId = ms.id
-				if ms.st == pb.ReportStatus_REPORT_STATUS_REJECTED {
-					iv.MyRejectionReason = ms.reason
+				iv.MyReportStatus = pb.ReportStatus(ms.Status)
+				iv.MyReportId = ms.ID.String()
+				iv.MyAwardedPercent = ms.AwardedPercent
+				iv.MyUnreadComments = myUnread[in.ID]
+				if iv.MyReportStatus == pb.ReportStatus_REPORT_STATUS_REJECTED {
+					iv.MyRejectionReason = derefOr(ms.RejectionReason, "")
 				}
 			}
 			pv.Incidents = append(pv.Incidents, iv)
@@ -121,16 +155,27 @@ func (s *PolygonServer) GetBluePolygon(ctx context.Context, _ *emptypb.Empty) (*
 		return &pb.GetBluePolygonResponse{}, nil
 	}
 
-	polID, err := s.repo.GetTeamPolygonID(ctx, tid)
+	polIDs, ev, err := s.bluePolygonIDs(ctx, tid)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return &pb.GetBluePolygonResponse{}, nil
+		return nil, err
+	}
+	out := &pb.GetBluePolygonResponse{}
+	for _, polID := range polIDs {
+		pv, err := s.bluePolygonView(ctx, tm, polID, eventIDOf(ev))
+		if err != nil {
+			return nil, err
 		}
-		return nil, status.Errorf(codes.Internal, "team polygon: %v", err)
+		out.Polygons = append(out.Polygons, pv)
 	}
-	if polID == uuid.Nil {
-		return &pb.GetBluePolygonResponse{}, nil
+	if len(out.Polygons) > 0 {
+		out.Polygon = out.Polygons[0]
 	}
+	return out, nil
+}
+
+// bluePolygonView — полигон глазами синей команды: только принятые красные отчёты по её копии.
+func (s *PolygonServer) bluePolygonView(ctx context.Context, tm *storage.Team, polID uuid.UUID, eventID *uuid.UUID) (*pb.PolygonBlueView, error) {
+	tid := tm.ID
 	pol, err := s.repo.GetPolygon(ctx, polID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "polygon: %v", err)
@@ -144,10 +189,11 @@ func (s *PolygonServer) GetBluePolygon(ctx context.Context, _ *emptypb.Empty) (*
 		incIDs = append(incIDs, in.ID)
 	}
 
-	accepted, err := s.repo.ListAcceptedRedReports(ctx, incIDs)
+	accepted, err := s.repo.ListAcceptedRedReports(ctx, incIDs, eventID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "accepted red: %v", err)
 	}
+	solvers := redSolvers(accepted)
 
 	teamCache := map[uuid.UUID]*storage.Team{}
 	getTeam := func(id uuid.UUID) *storage.Team {
@@ -162,37 +208,28 @@ func (s *PolygonServer) GetBluePolygon(ctx context.Context, _ *emptypb.Empty) (*
 		return t
 	}
 
-	myStatuses := map[uuid.UUID]struct {
-		id     string
-		st     pb.ReportStatus
-		reason string
-	}{}
+	myStatuses := map[uuid.UUID]*storage.ReportMeta{}
 	for _, inc := range incIDs {
-		if rid, st, reason, err := s.repo.GetLatestReportMetaForTeam(ctx, inc, tid); err == nil {
-			myStatuses[inc] = struct {
-				id     string
-				st     pb.ReportStatus
-				reason string
-			}{rid.String(), pb.ReportStatus(st), derefOr(reason, "")}
+		if ms, err := s.repo.GetLatestReportMetaForTeam(ctx, inc, tid, eventID); err == nil {
+			myStatuses[inc] = ms
 		}
 	}
-	var blueTeamPB *pb.Team
-	if bt, err := s.repo.FindBlueTeamByPolygon(ctx, pol.ID); err == nil && bt != nil {
-		blueTeamPB = &pb.Team{
-			Id:   bt.ID.String(),
-			Name: bt.Name,
-			Type: pb.TeamType(bt.Type),
-		}
+	myUnread, err := s.myUnreadComments(ctx, tid, myStatuses)
+	if err != nil {
+		return nil, err
 	}
 	pbPolygon := &pb.PolygonBlueView{
 		Id:          pol.ID.String(),
 		Name:        pol.Name,
 		Description: pol.Description,
 		CoverUrl:    pol.CoverURL,
-		BlueTeam:    blueTeamPB,
+		BlueTeam:    &pb.Team{Id: tm.ID.String(), Name: tm.Name, Type: pb.TeamType(tm.Type)},
 	}
 
 	for _, ar := range accepted {
+		if !targetsTeam(ar, tid) {
+			continue
+		}
 
 		iv := &pb.IncidentBlueView{
 			Id:                ar.IncidentID.String(),
@@ -200,6 +237,7 @@ func (s *PolygonServer) GetBluePolygon(ctx context.Context, _ *emptypb.Empty) (*
 			Description:       ar.IncidentDescription,
 			RedTeamReportId:   ar.ReportID.String(),
 			RedTeamReportTime: uint32(ar.Time),
+			RedAwardedPercent: ar.AwardedPercent,
 		}
 		if ar.BasePrize > 0 {
 			iv.RedPrize = ar.BasePrize
@@ -207,6 +245,9 @@ func (s *PolygonServer) GetBluePolygon(ctx context.Context, _ *emptypb.Empty) (*
 		if ar.BlueSharePercent > 0 {
 			iv.BluePrizeProcent = int64(ar.BlueSharePercent)
 		}
+		iv.ScoringMode = pb.IncidentScoringMode(ar.Scoring.Mode)
+		iv.CurrentPrize = ar.Scoring.Value(ar.BasePrize, solvers[ar.IncidentID])
+		iv.BlueSpeedBonus = ar.Bonuses.BlueSpeed
 		if tm := getTeam(ar.TeamID); tm != nil {
 			iv.RedTeam = &pb.Team{
 				Id:   tm.ID.String(),
@@ -215,15 +256,36 @@ func (s *PolygonServer) GetBluePolygon(ctx context.Context, _ *emptypb.Empty) (*
 			}
 		}
 		if ms, ok := myStatuses[ar.IncidentID]; ok {
-			iv.MyReportStatus = ms.st
-			iv.MyReportId = ms.id
-			if ms.st == pb.ReportStatus_REPORT_STATUS_REJECTED {
-				iv.MyRejectionReason = ms.reason
+			iv.MyReportStatus = pb.ReportStatus(ms.Status)
+			iv.MyReportId = ms.ID.String()
+			iv.MyAwardedPercent = ms.AwardedPercent
+			iv.MyUnreadComments = myUnread[ar.IncidentID]
+			if iv.MyReportStatus == pb.ReportStatus_REPORT_STATUS_REJECTED {
+				iv.MyRejectionReason = derefOr(ms.RejectionReason, "")
 			}
 		}
 		pbPolygon.Incidents = append(pbPolygon.Incidents, iv)
 	}
-	return &pb.GetBluePolygonResponse{Polygon: pbPolygon}, nil
+	return pbPolygon, nil
+}
+
+// targetsTeam — относится ли принятый красный отчёт к копии полигона синей команды.
+// Отчёты без цели (поданные до разделения копий) видны всем синим командам полигона.
+func targetsTeam(ar storage.AcceptedRedReportSummary, blueTeamID uuid.UUID) bool {
+	return ar.TargetTeamID == nil || *ar.TargetTeamID == blueTeamID
+}
+
+// polygonBlueTeams — синие команды полигона в виде pb.Team.
+func (s *PolygonServer) polygonBlueTeams(ctx context.Context, polygonID uuid.UUID) ([]*pb.Team, error) {
+	teams, err := s.repo.ListPolygonBlueTeams(ctx, polygonID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*pb.Team, 0, len(teams))
+	for _, t := range teams {
+		res = append(res, &pb.Team{Id: t.ID.String(), Name: t.Name, Type: pb.TeamType(t.Type)})
+	}
+	return res, nil
 }
 
 func (s *PolygonServer) GetRedIncidents(ctx context.Context, req *pb.GetRedIncidentsRequest) (*pb.GetRedIncidentsResponse, error) {
@@ -249,6 +311,14 @@ func (s *PolygonServer) GetRedIncidents(ctx context.Context, req *pb.GetRedIncid
 	if tm.Type != int32(pb.TeamType_TEAM_TYPE_RED) {
 		return &pb.GetRedIncidentsResponse{}, nil
 	}
+	ev, err := s.teamEvent(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+	if ev != nil && !ev.HasPolygon(pid) {
+		return &pb.GetRedIncidentsResponse{}, nil
+	}
+	eventID := eventIDOf(ev)
 	incidents, err := s.repo.ListIncidents(ctx, pid)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "incidents: %v", err)
@@ -258,7 +328,7 @@ func (s *PolygonServer) GetRedIncidents(ctx context.Context, req *pb.GetRedIncid
 	for _, in := range incidents {
 		incIDs = append(incIDs, in.ID)
 	}
-	acceptedList, err := s.repo.ListAcceptedRedReports(ctx, incIDs)
+	acceptedList, err := s.repo.ListAcceptedRedReports(ctx, incIDs, eventID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "accepted red reports: %v", err)
 	}
@@ -266,6 +336,25 @@ func (s *PolygonServer) GetRedIncidents(ctx context.Context, req *pb.GetRedIncid
 	for _, ar := range acceptedList {
 		acceptedMap[ar.IncidentID] = true
 	}
+	solvers := redSolvers(acceptedList)
+	cutoff, err := s.scoreCutoff(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "scoreboard freeze: %v", err)
+	}
+	myBonuses, err := s.repo.ListTeamIncidentBonuses(ctx, tid, storage.ScoreScope{EventID: eventID, Until: cutoff})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "team bonuses: %v", err)
+	}
+	myStatuses := map[uuid.UUID]*storage.ReportMeta{}
+	for _, inc := range incIDs {
+		if ms, err := s.repo.GetLatestReportMetaForTeam(ctx, inc, tid, eventID); err == nil {
+			myStatuses[inc] = ms
+		}
+	}
+	myUnread, err := s.myUnreadComments(ctx, tid, myStatuses)
+	if err != nil {
+		return nil, err
+	}
 
 	out := &pb.GetRedIncidentsResponse{}
 	for _, in := range incidents {
@@ -283,11 +372,19 @@ func (s *PolygonServer) GetRedIncidents(ctx context.Context, req *pb.GetRedIncid
 		if in.BlueSharePercent > 0 {
 			iv.BluePrizeProcent = int64(in.BlueSharePercent)
 		}
-		if rid, st, reason, err := s.repo.GetLatestReportMetaForTeam(ctx, in.ID, tid); err == nil {
-			iv.MyReportStatus = pb.ReportStatus(st)
-			iv.MyReportId = rid.String()
+		iv.ScoringMode = pb.IncidentScoringMode(in.Scoring.Mode)
+		iv.CurrentPrize = in.Scoring.Value(in.BasePrize, solvers[in.ID])
+		iv.FirstBloodBonus = in.Bonuses.FirstBlood
+		iv.SecondBloodBonus = in.Bonuses.SecondBlood
+		iv.ThirdBloodBonus = in.Bonuses.ThirdBlood
+		iv.MyBonus = myBonuses[in.ID]
+		if ms, ok := myStatuses[in.ID]; ok {
+			iv.MyReportStatus = pb.ReportStatus(ms.Status)
+			iv.MyReportId = ms.ID.String()
+			iv.MyAwardedPercent = ms.AwardedPercent
+			iv.MyUnreadComments = myUnread[in.ID]
 			if iv.MyReportStatus == pb.ReportStatus_REPORT_STATUS_REJECTED {
-				iv.MyRejectionReason = derefOr(reason, "")
+				iv.MyRejectionReason = derefOr(ms.RejectionReason, "")
 			}
 		}
 		out.Incidents = append(out.Incidents, iv)
@@ -295,6 +392,25 @@ func (s *PolygonServer) GetRedIncidents(ctx context.Context, req *pb.GetRedIncid
 	return out, nil
 }
 
+// bluePolygonIDs — полигоны синей команды; если команда участвует в соревновании — только его полигоны.
+func (s *PolygonServer) bluePolygonIDs(ctx context.Context, teamID uuid.UUID) ([]uuid.UUID, *storage.Event, error) {
+	polIDs, err := s.repo.ListTeamPolygonIDs(ctx, teamID)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "team polygons: %v", err)
+	}
+	ev, err := s.teamEvent(ctx, teamID)
+	if err != nil || ev == nil {
+		return polIDs, nil, err
+	}
+	res := make([]uuid.UUID, 0, len(polIDs))
+	for _, id := range polIDs {
+		if ev.HasPolygon(id) {
+			res = append(res, id)
+		}
+	}
+	return res, ev, nil
+}
+
 func (s *PolygonServer) GetBlueIncidents(ctx context.Context, _ *pb.GetBlueIncidentsRequest) (*pb.GetBlueIncidentsResponse, error) {
 	_, teamIDStr, _ := s.extractAuth(ctx)
 	if teamIDStr == "" {
@@ -311,40 +427,40 @@ func (s *PolygonServer) GetBlueIncidents(ctx context.Context, _ *pb.GetBlueIncid
 	if tm.Type != int32(pb.TeamType_TEAM_TYPE_BLUE) {
 		return &pb.GetBlueIncidentsResponse{}, nil
 	}
-	polID, err := s.repo.GetTeamPolygonID(ctx, tid)
+	polIDs, ev, err := s.bluePolygonIDs(ctx, tid)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "team polygon: %v", err)
-	}
-	if polID == uuid.Nil {
-		return &pb.GetBlueIncidentsResponse{}, nil
+		return nil, err
 	}
-	incidents, err := s.repo.ListIncidents(ctx, polID)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "incidents: %v", err)
+	eventID := eventIDOf(ev)
+	var incIDs []uuid.UUID
+	for _, polID := range polIDs {
+		incidents, err := s.repo.ListIncidents(ctx, polID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "incidents: %v", err)
+		}
+		for _, in := range incidents {
+			incIDs = append(incIDs, in.ID)
+		}
 	}
-	incIDs := make([]uuid.UUID, 0, len(incidents))
-	for _, in := range incidents {
-		incIDs = append(incIDs, in.ID)
+	if len(incIDs) == 0 {
+		return &pb.GetBlueIncidentsResponse{}, nil
 	}
-	accepted, err := s.repo.ListAcceptedRedReports(ctx, incIDs)
+	accepted, err := s.repo.ListAcceptedRedReports(ctx, incIDs, eventID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "accepted red: %v", err)
 	}
+	solvers := redSolvers(accepted)
 
-	myStatuses := map[uuid.UUID]struct {
-		id     string
-		st     pb.ReportStatus
-		reason string
-	}{}
+	myStatuses := map[uuid.UUID]*storage.ReportMeta{}
 	for _, inc := range incIDs {
-		if rid, st, reason, err := s.repo.GetLatestReportMetaForTeam(ctx, inc, tid); err == nil {
-			myStatuses[inc] = struct {
-				id     string
-				st     pb.ReportStatus
-				reason string
-			}{rid.String(), pb.ReportStatus(st), derefOr(reason, "")}
+		if ms, err := s.repo.GetLatestReportMetaForTeam(ctx, inc, tid, eventID); err == nil {
+			myStatuses[inc] = ms
 		}
 	}
+	myUnread, err := s.myUnreadComments(ctx, tid, myStatuses)
+	if err != nil {
+		return nil, err
+	}
 	teamCache := map[uuid.UUID]*storage.Team{}
 	getTeam := func(id uuid.UUID) *storage.Team {
 		if v, ok := teamCache[id]; ok {
@@ -359,12 +475,16 @@ func (s *PolygonServer) GetBlueIncidents(ctx context.Context, _ *pb.GetBlueIncid
 	}
 	out := &pb.GetBlueIncidentsResponse{}
 	for _, ar := range accepted {
+		if !targetsTeam(ar, tid) {
+			continue
+		}
 		iv := &pb.IncidentBlueView{
 			Id:                ar.IncidentID.String(),
 			Name:              ar.IncidentName,
 			Description:       ar.IncidentDescription,
 			RedTeamReportId:   ar.ReportID.String(),
 			RedTeamReportTime: uint32(ar.Time),
+			RedAwardedPercent: ar.AwardedPercent,
 		}
 		if ar.BasePrize > 0 {
 			iv.RedPrize = ar.BasePrize
@@ -372,6 +492,9 @@ func (s *PolygonServer) GetBlueIncidents(ctx context.Context, _ *pb.GetBlueIncid
 		if ar.BlueSharePercent > 0 {
 			iv.BluePrizeProcent = int64(ar.BlueSharePercent)
 		}
+		iv.ScoringMode = pb.IncidentScoringMode(ar.Scoring.Mode)
+		iv.CurrentPrize = ar.Scoring.Value(ar.BasePrize, solvers[ar.IncidentID])
+		iv.BlueSpeedBonus = ar.Bonuses.BlueSpeed
 		if tm := getTeam(ar.TeamID); tm != nil {
 			iv.RedTeam = &pb.Team{
 				Id:   tm.ID.String(),
@@ -380,10 +503,12 @@ func (s *PolygonServer) GetBlueIncidents(ctx context.Context, _ *pb.GetBlueIncid
 			}
 		}
 		if ms, ok := myStatuses[ar.IncidentID]; ok {
-			iv.MyReportStatus = ms.st
-			iv.MyReportId = ms.id
-			if ms.st == pb.ReportStatus_REPORT_STATUS_REJECTED {
-				iv.MyRejectionReason = ms.reason
+			iv.MyReportStatus = pb.ReportStatus(ms.Status)
+			iv.MyReportId = ms.ID.String()
+			iv.MyAwardedPercent = ms.AwardedPercent
+			iv.MyUnreadComments = myUnread[ar.IncidentID]
+			if iv.MyReportStatus == pb.ReportStatus_REPORT_STATUS_REJECTED {
+				iv.MyRejectionReason = derefOr(ms.RejectionReason, "")
 			}
 		}
 		out.Incidents = append(out.Incidents, iv)
@@ -432,35 +557,12 @@ func (s *PolygonServer) CreatePolygon(ctx context.Context, req *pb.CreatePolygon
 	if err := s.repo.CreatePolygon(ctx, id, strings.TrimSpace(req.GetName()), req.GetDescription(), req.GetCoverUrl(), ""); err != nil {
 		return nil, status.Errorf(codes.Internal, "create: %v", err)
 	}
-	var blueTeamPB *pb.Team
 	if bt := strings.TrimSpace(req.GetBlueTeamId()); bt != "" {
-		tid, err := uuid.Parse(bt)
-		if err != nil {
-			return nil, status.Error(codes.InvalidArgument, "invalid blue_team_id")
-		}
-		tm, err := s.repo.GetTeam(ctx, tid)
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "team: %v", err)
-		}
-		if tm.Type != int32(pb.TeamType_TEAM_TYPE_BLUE) {
-			return nil, status.Error(codes.InvalidArgument, "team is not blue")
-		}
-		if err := s.repo.SetTeamPolygon(ctx, tid, id); err != nil {
-			return nil, status.Errorf(codes.Internal, "set team polygon: %v", err)
-		}
-		blueTeamPB = &pb.Team{
-			Id:   tm.ID.String(),
-			Name: tm.Name,
-			Type: pb.TeamType(tm.Type),
+		if err := s.addPolygonBlueTeam(ctx, id, bt); err != nil {
+			return nil, err
 		}
 	}
-	return &pb.Polygon{
-		Id:          id.String(),
-		Name:        req.GetName(),
-		Description: req.GetDescription(),
-		CoverUrl:    req.GetCoverUrl(),
-		BlueTeam:    blueTeamPB,
-	}, nil
+	return s.toPBPolygon(ctx, id)
 }
 func (s *PolygonServer) EditPolygon(ctx context.Context, req *pb.EditPolygonRequest) (*pb.Polygon, error) {
 	if req.GetId() == "" {
@@ -489,39 +591,109 @@ func (s *PolygonServer) EditPolygon(ctx context.Context, req *pb.EditPolygonRequ
 		}
 		return nil, status.Errorf(codes.Internal, "update: %v", err)
 	}
-	p, err := s.repo.GetPolygon(ctx, id)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "get: %v", err)
-	}
-	var blueTeamPB *pb.Team
 	if bt := strings.TrimSpace(req.GetBlueTeamId()); bt != "" {
-		tid, err := uuid.Parse(bt)
-		if err != nil {
-			return nil, status.Error(codes.InvalidArgument, "invalid blue_team_id")
-		}
-		tm, err := s.repo.GetTeam(ctx, tid)
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "team: %v", err)
-		}
-		if tm.Type != int32(pb.TeamType_TEAM_TYPE_BLUE) {
-			return nil, status.Error(codes.InvalidArgument, "team is not blue")
+		if err := s.addPolygonBlueTeam(ctx, id, bt); err != nil {
+			return nil, err
 		}
-		if err := s.repo.SetTeamPolygon(ctx, tid, id); err != nil {
-			return nil, status.Errorf(codes.Internal, "set team polygon: %v", err)
+	}
+	return s.toPBPolygon(ctx, id)
+}
+
+// addPolygonBlueTeam проверяет, что команда синяя, и назначает её на полигон.
+func (s *PolygonServer) addPolygonBlueTeam(ctx context.Context, polygonID uuid.UUID, teamID string) error {
+	tid, err := uuid.Parse(teamID)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid blue_team_id")
+	}
+	tm, err := s.repo.GetTeam(ctx, tid)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return status.Error(codes.NotFound, "team not found")
 		}
-		blueTeamPB = &pb.Team{
-			Id:   tm.ID.String(),
-			Name: tm.Name,
-			Type: pb.TeamType(tm.Type),
+		return status.Errorf(codes.Internal, "team: %v", err)
+	}
+	if tm.Type != int32(pb.TeamType_TEAM_TYPE_BLUE) {
+		return status.Error(codes.InvalidArgument, "team is not blue")
+	}
+	if err := s.repo.AddPolygonBlueTeam(ctx, polygonID, tid); err != nil {
+		return status.Errorf(codes.Internal, "add polygon blue team: %v", err)
+	}
+	return nil
+}
+
+// toPBPolygon — полигон без инцидентов, со списком назначенных синих команд.
+func (s *PolygonServer) toPBPolygon(ctx context.Context, id uuid.UUID) (*pb.Polygon, error) {
+	p, err := s.repo.GetPolygon(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "polygon not found")
 		}
+		return nil, status.Errorf(codes.Internal, "get: %v", err)
 	}
-	return &pb.Polygon{
+	blueTeams, err := s.polygonBlueTeams(ctx, id)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "polygon blue teams: %v", err)
+	}
+	res := &pb.Polygon{
 		Id:          p.ID.String(),
 		Name:        p.Name,
 		Description: p.Description,
 		CoverUrl:    p.CoverURL,
-		BlueTeam:    blueTeamPB,
-	}, nil
+		BlueTeams:   blueTeams,
+	}
+	if len(blueTeams) > 0 {
+		res.BlueTeam = blueTeams[0]
+	}
+	return res, nil
+}
+
+func (s *PolygonServer) AddPolygonBlueTeam(ctx context.Context, req *pb.PolygonBlueTeamRequest) (*pb.Polygon, error) {
+	pid, err := parsePolygonBlueTeamRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetPolygon(ctx, pid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "polygon not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get polygon: %v", err)
+	}
+	if err := s.addPolygonBlueTeam(ctx, pid, req.GetTeamId()); err != nil {
+		return nil, err
+	}
+	return s.toPBPolygon(ctx, pid)
+}
+
+func (s *PolygonServer) RemovePolygonBlueTeam(ctx context.Context, req *pb.PolygonBlueTeamRequest) (*pb.Polygon, error) {
+	pid, err := parsePolygonBlueTeamRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	tid, err := uuid.Parse(req.GetTeamId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid team_id")
+	}
+	if err := s.repo.RemovePolygonBlueTeam(ctx, pid, tid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "team is not assigned to polygon")
+		}
+		return nil, status.Errorf(codes.Internal, "remove polygon blue team: %v", err)
+	}
+	return s.toPBPolygon(ctx, pid)
+}
+
+func parsePolygonBlueTeamRequest(req *pb.PolygonBlueTeamRequest) (uuid.UUID, error) {
+	if strings.TrimSpace(req.GetPolygonId()) == "" {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "polygon_id required")
+	}
+	if strings.TrimSpace(req.GetTeamId()) == "" {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "team_id required")
+	}
+	pid, err := uuid.Parse(req.GetPolygonId())
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "invalid polygon_id")
+	}
+	return pid, nil
 }
 func (s *PolygonServer) DeletePolygon(ctx context.Context, req *pb.DeletePolygonRequest) (*emptypb.Empty, error) {
 	if req.GetId() == "" {
@@ -547,13 +719,13 @@ func (s *PolygonServer) ListPolygons(ctx context.Context, _ *emptypb.Empty) (*pb
 	}
 	resp := &pb.AdminListPolygonsResponse{}
 	for _, p := range polys {
+		blueTeams, err := s.polygonBlueTeams(ctx, p.ID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "polygon blue teams: %v", err)
+		}
 		var blueTeamPB *pb.Team
-		if bt, err := s.repo.FindBlueTeamByPolygon(ctx, p.ID); err == nil && bt != nil {
-			blueTeamPB = &pb.Team{
-				Id:   bt.ID.String(),
-				Name: bt.Name,
-				Type: pb.TeamType(bt.Type),
-			}
+		if len(blueTeams) > 0 {
+			blueTeamPB = blueTeams[0]
 		}
 
 		incIDs := make([]uuid.UUID, 0, len(p.Incidents))
@@ -602,6 +774,7 @@ func (s *PolygonServer) ListPolygons(ctx context.Context, _ *emptypb.Empty) (*pb
 							Steps:           pbSteps,
 							Time:            uint32(r.Time),
 							Status:          pb.ReportStatus(r.Status),
+							AwardedPercent:  r.AwardedPercent,
 							RejectionReason: r.RejectionReason,
 							RedTeamReportId: redRef,
 						})
@@ -626,6 +799,23 @@ func (s *PolygonServer) ListPolygons(ctx context.Context, _ *emptypb.Empty) (*pb
 			if in.BlueSharePercent > 0 {
 				inc.BluePrizeProcent = int64(in.BlueSharePercent)
 			}
+			inc.ScoringMode = pb.IncidentScoringMode(in.Scoring.Mode)
+			inc.DynamicMinimum = in.Scoring.DynamicMinimum
+			inc.DynamicDecay = in.Scoring.DynamicDecay
+			solvedBy := map[uuid.UUID]struct{}{}
+			for _, r := range redReportsByIncident[in.ID] {
+				if reportSolved(r.Status) {
+					solvedBy[r.TeamID] = struct{}{}
+				}
+			}
+			inc.CurrentPrize = in.Scoring.Value(in.BasePrize, len(solvedBy))
+			inc.FirstBloodBonus = in.Bonuses.FirstBlood
+			inc.SecondBloodBonus = in.Bonuses.SecondBlood
+			inc.ThirdBloodBonus = in.Bonuses.ThirdBlood
+			inc.BlueSpeedBonus = in.Bonuses.BlueSpeed
+			inc.ReviewPolicy = pb.ReviewPolicy(in.Review.Mode)
+			inc.ReviewJudges = uint32(in.Review.Judges)
+			inc.HeadJudgeId = optionalUUIDString(in.Review.HeadJudgeID)
 			if rr := redReportsByIncident[in.ID]; len(rr) > 0 {
 				inc.RedReports = toPBReports(rr)
 			}
@@ -640,6 +830,7 @@ func (s *PolygonServer) ListPolygons(ctx context.Context, _ *emptypb.Empty) (*pb
 			Description: p.Description,
 			CoverUrl:    p.CoverURL,
 			BlueTeam:    blueTeamPB,
+			BlueTeams:   blueTeams,
 			Incidents:   incs,
 		})
 	}
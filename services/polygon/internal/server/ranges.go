@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// GetMyCyberRange — площадка, в которую входят полигоны, доступные команде вызывающего.
+// Полигоны и материалы фильтруются так же, как в GetRedPolygons/GetBluePolygon и GetInitialItems.
+func (s *PolygonServer) GetMyCyberRange(ctx context.Context, _ *emptypb.Empty) (*pb.CyberRange, error) {
+	_, teamIDStr, _ := s.extractAuth(ctx)
+	if teamIDStr == "" {
+		return &pb.CyberRange{}, nil
+	}
+	tid, err := uuid.Parse(teamIDStr)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid team id")
+	}
+	polIDs, err := s.teamPolygonIDs(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+	if len(polIDs) == 0 {
+		return nil, status.Error(codes.NotFound, "cyber range not found")
+	}
+	cr, err := s.repo.FindCyberRangeByPolygons(ctx, polIDs)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "cyber range not found")
+		}
+		return nil, status.Errorf(codes.Internal, "find range: %v", err)
+	}
+	visible := make(map[uuid.UUID]bool, len(polIDs))
+	for _, id := range polIDs {
+		visible[id] = true
+	}
+	res := &pb.CyberRange{Id: cr.ID.String(), Name: cr.Name, Description: cr.Description}
+	for _, id := range cr.PolygonIDs {
+		if !visible[id] {
+			continue
+		}
+		p, err := s.toPBPolygon(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		res.Polygons = append(res.Polygons, p)
+	}
+	items, err := s.callerInitialItems(ctx, &cr.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		res.InitialData = append(res.InitialData, toPBInitialItem(&items[i]))
+	}
+	return res, nil
+}
+
+// teamPolygonIDs — полигоны, доступные команде: у синей — защищаемые ею, у красной — все
+// полигоны с инцидентами; в обоих случаях в пределах текущего соревнования.
+func (s *PolygonServer) teamPolygonIDs(ctx context.Context, teamID uuid.UUID) ([]uuid.UUID, error) {
+	tm, err := s.repo.GetTeam(ctx, teamID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "team: %v", err)
+	}
+	if tm.Type == int32(pb.TeamType_TEAM_TYPE_BLUE) {
+		ids, _, err := s.bluePolygonIDs(ctx, teamID)
+		return ids, err
+	}
+	polys, err := s.repo.ListPolygonsWithIncidents(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list polygons: %v", err)
+	}
+	ev, err := s.teamEvent(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(polys))
+	for _, p := range polys {
+		if ev == nil || ev.HasPolygon(p.ID) {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *PolygonServer) ListCyberRanges(ctx context.Context, _ *emptypb.Empty) (*pb.ListCyberRangesResponse, error) {
+	list, err := s.repo.ListCyberRanges(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list ranges: %v", err)
+	}
+	resp := &pb.ListCyberRangesResponse{Ranges: make([]*pb.CyberRange, 0, len(list))}
+	for i := range list {
+		cr, err := s.toPBCyberRange(ctx, &list[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Ranges = append(resp.Ranges, cr)
+	}
+	return resp, nil
+}
+
+func (s *PolygonServer) GetCyberRange(ctx context.Context, req *pb.CyberRangeRequest) (*pb.CyberRange, error) {
+	if strings.TrimSpace(req.GetId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	return s.loadPBCyberRange(ctx, id)
+}
+
+func (s *PolygonServer) CreateCyberRange(ctx context.Context, req *pb.CreateCyberRangeRequest) (*pb.CyberRange, error) {
+	if strings.TrimSpace(req.GetName()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+	cr := &storage.CyberRange{ID: uuid.New(), Name: strings.TrimSpace(req.GetName()), Description: req.GetDescription()}
+	for _, v := range req.GetPolygonIds() {
+		pid, err := uuid.Parse(v)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid polygon_ids")
+		}
+		cr.PolygonIDs = append(cr.PolygonIDs, pid)
+	}
+	if err := s.repo.CreateCyberRange(ctx, cr); err != nil {
+		if errors.Is(err, storage.ErrPolygonInOtherRange) {
+			return nil, status.Error(codes.FailedPrecondition, "polygon belongs to another range")
+		}
+		return nil, status.Errorf(codes.Internal, "create range: %v", err)
+	}
+	return s.loadPBCyberRange(ctx, cr.ID)
+}
+
+func (s *PolygonServer) EditCyberRange(ctx context.Context, req *pb.EditCyberRangeRequest) (*pb.CyberRange, error) {
+	if strings.TrimSpace(req.GetId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	var namePtr *string
+	if req.Name != nil {
+		v := strings.TrimSpace(req.GetName())
+		if v == "" {
+			return nil, status.Error(codes.InvalidArgument, "name required")
+		}
+		namePtr = &v
+	}
+	if err := s.repo.UpdateCyberRange(ctx, id, namePtr, req.Description); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "cyber range not found")
+		}
+		return nil, status.Errorf(codes.Internal, "update range: %v", err)
+	}
+	return s.loadPBCyberRange(ctx, id)
+}
+
+func (s *PolygonServer) DeleteCyberRange(ctx context.Context, req *pb.CyberRangeRequest) (*emptypb.Empty, error) {
+	if strings.TrimSpace(req.GetId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	if err := s.repo.DeleteCyberRange(ctx, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "cyber range not found")
+		}
+		return nil, status.Errorf(codes.Internal, "delete range: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *PolygonServer) AddCyberRangePolygon(ctx context.Context, req *pb.CyberRangePolygonRequest) (*pb.CyberRange, error) {
+	rid, pid, err := s.parseRangePolygon(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetPolygon(ctx, pid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "polygon not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get polygon: %v", err)
+	}
+	if err := s.repo.AddCyberRangePolygon(ctx, rid, pid); err != nil {
+		if errors.Is(err, storage.ErrPolygonInOtherRange) {
+			return nil, status.Error(codes.FailedPrecondition, "polygon belongs to another range")
+		}
+		return nil, status.Errorf(codes.Internal, "add range polygon: %v", err)
+	}
+	return s.loadPBCyberRange(ctx, rid)
+}
+
+func (s *PolygonServer) RemoveCyberRangePolygon(ctx context.Context, req *pb.CyberRangePolygonRequest) (*pb.CyberRange, error) {
+	rid, pid, err := s.parseRangePolygon(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RemoveCyberRangePolygon(ctx, rid, pid); err != nil {
+		return nil, status.Errorf(codes.Internal, "remove range polygon: %v", err)
+	}
+	return s.loadPBCyberRange(ctx, rid)
+}
+
+// parseRangePolygon разбирает id площадки и полигона и проверяет, что площадка существует.
+func (s *PolygonServer) parseRangePolygon(ctx context.Context, req *pb.CyberRangePolygonRequest) (uuid.UUID, uuid.UUID, error) {
+	if strings.TrimSpace(req.GetRangeId()) == "" {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "range_id required")
+	}
+	if strings.TrimSpace(req.GetPolygonId()) == "" {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "polygon_id required")
+	}
+	rid, err := uuid.Parse(req.GetRangeId())
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "invalid range_id")
+	}
+	pid, err := uuid.Parse(req.GetPolygonId())
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "invalid polygon_id")
+	}
+	if _, err := s.repo.GetCyberRange(ctx, rid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, uuid.Nil, status.Error(codes.NotFound, "cyber range not found")
+		}
+		return uuid.Nil, uuid.Nil, status.Errorf(codes.Internal, "get range: %v", err)
+	}
+	return rid, pid, nil
+}
+
+func (s *PolygonServer) loadPBCyberRange(ctx context.Context, id uuid.UUID) (*pb.CyberRange, error) {
+	cr, err := s.repo.GetCyberRange(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "cyber range not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get range: %v", err)
+	}
+	return s.toPBCyberRange(ctx, cr)
+}
+
+// toPBCyberRange — площадка целиком, со всеми полигонами и материалами (для администратора).
+func (s *PolygonServer) toPBCyberRange(ctx context.Context, cr *storage.CyberRange) (*pb.CyberRange, error) {
+	res := &pb.CyberRange{Id: cr.ID.String(), Name: cr.Name, Description: cr.Description}
+	for _, id := range cr.PolygonIDs {
+		p, err := s.toPBPolygon(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		res.Polygons = append(res.Polygons, p)
+	}
+	items, err := s.repo.ListRangeInitialItems(ctx, cr.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "range initial items: %v", err)
+	}
+	for i := range items {
+		res.InitialData = append(res.InitialData, toPBInitialItem(&items[i]))
+	}
+	return res, nil
+}
@@ -0,0 +1,379 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	gatewayfile "github.com/black-06/grpc-gateway-file"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	httpbody "google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const attachmentsPrefix = "report_attachments"
+
+// attachmentSweepGrace — сколько объект может пролежать в S3 без записи в report_attachments:
+// загрузка сначала кладёт файл и только потом пишет метаданные.
+const attachmentSweepGrace = time.Hour
+
+func (s *PolygonServer) UploadReportAttachment(stream pb.PolygonClientService_UploadReportAttachmentServer) error {
+	ctx := stream.Context()
+	formData, err := gatewayfile.NewFormData(stream, 50*1024*1024)
+	if err != nil {
+		return status.Errorf(codes.Internal, "form: %v", err)
+	}
+	defer formData.RemoveAll()
+	reportID, err := parseReportID(formData.FirstValue("report_id"))
+	if err != nil {
+		return err
+	}
+	rp, err := s.editableTeamReport(ctx, reportID)
+	if err != nil {
+		return err
+	}
+	var stepNumber *int32
+	if v := strings.TrimSpace(formData.FirstValue("step_number")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > len(rp.Steps) {
+			return status.Error(codes.InvalidArgument, "invalid step_number")
+		}
+		sn := int32(n)
+		stepNumber = &sn
+	}
+	fileHeader := formData.FirstFile("file")
+	if fileHeader == nil {
+		return status.Error(codes.InvalidArgument, "file field required")
+	}
+	f, err := fileHeader.Open()
+	if err != nil {
+		return status.Errorf(codes.Internal, "open: %v", err)
+	}
+	defer f.Close()
+	buf := new(bytes.Buffer)
+	if _, err := io.Copy(buf, f); err != nil {
+		return status.Errorf(codes.Internal, "read: %v", err)
+	}
+	if buf.Len() == 0 {
+		return status.Error(codes.InvalidArgument, "empty file")
+	}
+	if s.s3 == nil {
+		return status.Error(codes.FailedPrecondition, "s3 not configured")
+	}
+	attID := uuid.New()
+	key := s.s3.ObjectKey(attachmentsPrefix, reportID.String(), attID.String())
+	ct := contentTypeOrDefault(fileHeader.Header.Get("Content-Type"))
+	_, size, err := s.s3.PutBytes(ctx, key, buf.Bytes(), ct)
+	if err != nil {
+		return status.Errorf(codes.Internal, "s3 put: %v", err)
+	}
+	att := &storage.Attachment{
+		ID:          attID,
+		ReportID:    reportID,
+		StepNumber:  stepNumber,
+		URL:         "/v1/report/attachments/" + attID.String(),
+		ObjectKey:   key,
+		ContentType: ct,
+		Size:        size,
+	}
+	if err := s.repo.InsertAttachment(ctx, att); err != nil {
+		if err2 := s.s3.DeleteObject(ctx, key); err2 != nil {
+			log.Printf("attachments: remove %s after failed insert: %v", key, err2)
+		}
+		return status.Errorf(codes.Internal, "insert attachment: %v", err)
+	}
+	return stream.SendAndClose(&pb.UploadReportAttachmentResponse{Attachment: toPBReportAttachment(att)})
+}
+
+func (s *PolygonServer) DownloadReportAttachment(req *pb.DownloadReportAttachmentRequest, stream pb.PolygonClientService_DownloadReportAttachmentServer) error {
+	ctx := stream.Context()
+	if req.GetId() == "" {
+		return status.Error(codes.InvalidArgument, "id required")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid id")
+	}
+	if s.s3 == nil {
+		return status.Error(codes.FailedPrecondition, "s3 not configured")
+	}
+	att, err := s.repo.GetAttachment(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return status.Error(codes.NotFound, "attachment not found")
+		}
+		return status.Errorf(codes.Internal, "get attachment: %v", err)
+	}
+	if req.GetSignature() != "" {
+		if !s.validAttachmentSignature(id, req.GetExpires(), req.GetSignature()) {
+			return status.Error(codes.PermissionDenied, "invalid or expired link")
+		}
+	} else if err := s.checkAttachmentAccess(ctx, att.ReportID); err != nil {
+		return err
+	}
+	obj, _, ct, err := s.s3.GetObject(ctx, att.ObjectKey)
+	if err != nil {
+		return status.Error(codes.NotFound, "attachment not found")
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return status.Errorf(codes.Internal, "read: %v", err)
+	}
+	return stream.Send(&httpbody.HttpBody{ContentType: ct, Data: data})
+}
+
+func (s *PolygonServer) GetReportAttachmentLink(ctx context.Context, req *pb.GetReportAttachmentLinkRequest) (*pb.ReportAttachmentLink, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	att, err := s.repo.GetAttachment(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "attachment not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get attachment: %v", err)
+	}
+	if err := s.checkAttachmentAccess(ctx, att.ReportID); err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(s.attachmentLinkTTL)
+	expires := expiresAt.Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", s.attachmentSignature(id, expires))
+	return &pb.ReportAttachmentLink{
+		Url:       att.URL + "?" + q.Encode(),
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// checkAttachmentAccess — вложения отчёта видят администраторы, команда-владелец и синяя команда,
+// защищающаяся от принятого красного отчёта (как в targetsTeam: отчёт без цели — все синие
+// команды полигона).
+func (s *PolygonServer) checkAttachmentAccess(ctx context.Context, reportID uuid.UUID) error {
+	if isAdminCaller(ctx) {
+		return nil
+	}
+	_, teamID, err := s.extractAuth(ctx)
+	if err != nil {
+		return err
+	}
+	if teamID == "" {
+		return status.Error(codes.PermissionDenied, "forbidden")
+	}
+	tid, err := uuid.Parse(teamID)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid team id")
+	}
+	rp, err := s.repo.GetReport(ctx, reportID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return status.Error(codes.NotFound, "attachment not found")
+		}
+		return status.Errorf(codes.Internal, "get: %v", err)
+	}
+	if rp.TeamID == tid {
+		return nil
+	}
+	if !reportSolved(rp.Status) {
+		return status.Error(codes.PermissionDenied, "forbidden")
+	}
+	if rp.TargetTeamID != nil {
+		if *rp.TargetTeamID == tid {
+			return nil
+		}
+		return status.Error(codes.PermissionDenied, "forbidden")
+	}
+	tm, err := s.repo.GetTeam(ctx, tid)
+	if err != nil {
+		return status.Errorf(codes.Internal, "team: %v", err)
+	}
+	if tm.Type != int32(pb.TeamType_TEAM_TYPE_BLUE) {
+		return status.Error(codes.PermissionDenied, "forbidden")
+	}
+	inc, err := s.repo.GetIncident(ctx, rp.IncidentID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "get incident: %v", err)
+	}
+	polIDs, _, err := s.bluePolygonIDs(ctx, tid)
+	if err != nil {
+		return err
+	}
+	for _, pid := range polIDs {
+		if pid == inc.PolygonID {
+			return nil
+		}
+	}
+	return status.Error(codes.PermissionDenied, "forbidden")
+}
+
+// attachmentSignature — HMAC-SHA256 от id вложения и срока действия ссылки.
+func (s *PolygonServer) attachmentSignature(id uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, s.jwtSecret)
+	mac.Write([]byte("report_attachment:" + id.String() + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *PolygonServer) validAttachmentSignature(id uuid.UUID, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.attachmentSignature(id, expires)))
+}
+
+func (s *PolygonServer) DeleteReportAttachment(ctx context.Context, req *pb.DeleteReportAttachmentRequest) (*emptypb.Empty, error) {
+	if strings.TrimSpace(req.GetAttachmentId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "attachment_id required")
+	}
+	id, err := uuid.Parse(req.GetAttachmentId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid attachment_id")
+	}
+	att, err := s.repo.GetAttachment(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "attachment not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get attachment: %v", err)
+	}
+	if _, err := s.editableTeamReport(ctx, att.ReportID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteAttachment(ctx, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "attachment not found")
+		}
+		return nil, status.Errorf(codes.Internal, "delete attachment: %v", err)
+	}
+	// Если объект удалить не удалось, его подберёт runAttachmentSweeper.
+	if s.s3 != nil {
+		if err := s.s3.DeleteObject(ctx, att.ObjectKey); err != nil {
+			log.Printf("attachments: remove %s: %v", att.ObjectKey, err)
+		}
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// reportEditable — вложения можно менять, пока отчёт не принят: на проверке или после отклонения.
+func reportEditable(st int32) bool {
+	switch pb.ReportStatus(st) {
+	case pb.ReportStatus_REPORT_STATUS_PENDING, pb.ReportStatus_REPORT_STATUS_REJECTED:
+		return true
+	}
+	return false
+}
+
+// editableTeamReport — отчёт команды вызывающего, который сейчас можно менять.
+func (s *PolygonServer) editableTeamReport(ctx context.Context, reportID uuid.UUID) (*storage.Report, error) {
+	_, teamID, err := s.extractAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := s.repo.GetReport(ctx, reportID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "report not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get: %v", err)
+	}
+	if teamID == "" || rp.TeamID.String() != teamID {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	if !reportEditable(rp.Status) {
+		return nil, status.Error(codes.FailedPrecondition, "report is not editable")
+	}
+	if rp.EventID != nil {
+		ev, err := s.repo.GetEvent(ctx, *rp.EventID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "get event: %v", err)
+		}
+		if !ev.Running(time.Now()) {
+			return nil, status.Error(codes.FailedPrecondition, "event is not running")
+		}
+	}
+	return rp, nil
+}
+
+// runAttachmentSweeper периодически удаляет из S3 вложения без записи в report_attachments:
+// оставшиеся после неудачной загрузки, удалённых отчётов (записи удаляются каскадом)
+// и загруженные до привязки вложений к отчётам.
+func (s *PolygonServer) runAttachmentSweeper(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		s.sweepAttachments(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *PolygonServer) sweepAttachments(ctx context.Context) {
+	objs, err := s.s3.ListObjects(ctx, attachmentsPrefix+"/")
+	if err != nil {
+		log.Printf("attachments sweeper: list objects: %v", err)
+		return
+	}
+	cutoff := time.Now().Add(-attachmentSweepGrace)
+	keys := make([]string, 0, len(objs))
+	for _, o := range objs {
+		if o.LastModified.Before(cutoff) {
+			keys = append(keys, o.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	known, err := s.repo.FilterAttachmentKeys(ctx, keys)
+	if err != nil {
+		log.Printf("attachments sweeper: filter keys: %v", err)
+		return
+	}
+	for _, k := range keys {
+		if known[k] {
+			continue
+		}
+		if err := s.s3.DeleteObject(ctx, k); err != nil {
+			log.Printf("attachments sweeper: remove %s: %v", k, err)
+			continue
+		}
+		log.Printf("attachments sweeper: removed orphaned %s", k)
+	}
+}
+
+func toPBReportAttachment(a *storage.Attachment) *pb.ReportAttachment {
+	res := &pb.ReportAttachment{
+		Id:          a.ID.String(),
+		Url:         a.URL,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		ReportId:    a.ReportID.String(),
+		CreatedAt:   a.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if a.StepNumber != nil {
+		res.StepNumber = uint32(*a.StepNumber)
+	}
+	return res
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *PolygonServer) ListReportComments(ctx context.Context, req *pb.ListReportCommentsRequest) (*pb.ListReportCommentsResponse, error) {
+	reportID, err := parseReportID(req.GetReportId())
+	if err != nil {
+		return nil, err
+	}
+	_, teamID, err := s.commentAccess(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.repo.ListReportComments(ctx, reportID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list comments: %v", err)
+	}
+	if teamID != nil && len(list) > 0 {
+		if err := s.repo.MarkReportCommentsRead(ctx, reportID, *teamID, list[len(list)-1].CreatedAt); err != nil {
+			return nil, status.Errorf(codes.Internal, "mark read: %v", err)
+		}
+	}
+	resp := &pb.ListReportCommentsResponse{Comments: make([]*pb.ReportComment, 0, len(list))}
+	for i := range list {
+		resp.Comments = append(resp.Comments, toPBReportComment(&list[i]))
+	}
+	return resp, nil
+}
+
+func (s *PolygonServer) AddReportComment(ctx context.Context, req *pb.AddReportCommentRequest) (*pb.ReportComment, error) {
+	reportID, err := parseReportID(req.GetReportId())
+	if err != nil {
+		return nil, err
+	}
+	body := strings.TrimSpace(req.GetBody())
+	if body == "" {
+		return nil, status.Error(codes.InvalidArgument, "body required")
+	}
+	authorID, err := s.callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rp, teamID, err := s.commentAccess(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	c := &storage.ReportComment{ReportID: reportID, AuthorID: authorID, AuthorTeamID: teamID, Body: body}
+	if n := req.GetStepNumber(); n != 0 {
+		if n < 0 || int(n) > len(rp.Steps) {
+			return nil, status.Error(codes.InvalidArgument, "invalid step_number")
+		}
+		c.StepNumber = &n
+	}
+	if err := s.repo.InsertReportComment(ctx, c); err != nil {
+		return nil, status.Errorf(codes.Internal, "insert comment: %v", err)
+	}
+	return toPBReportComment(c), nil
+}
+
+// commentAccess — обсуждать отчёт могут судьи (администраторы) и команда-владелец.
+// Для команды возвращается её id, для судьи — nil.
+func (s *PolygonServer) commentAccess(ctx context.Context, reportID uuid.UUID) (*storage.Report, *uuid.UUID, error) {
+	rp, err := s.repo.GetReport(ctx, reportID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, status.Error(codes.NotFound, "report not found")
+		}
+		return nil, nil, status.Errorf(codes.Internal, "get: %v", err)
+	}
+	if isAdminCaller(ctx) {
+		return rp, nil, nil
+	}
+	_, teamID, err := s.extractAuth(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if teamID == "" || rp.TeamID.String() != teamID {
+		return nil, nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	return rp, &rp.TeamID, nil
+}
+
+// myUnreadComments — непрочитанные командой комментарии судей к её последним отчётам по инцидентам.
+func (s *PolygonServer) myUnreadComments(ctx context.Context, teamID uuid.UUID, myStatuses map[uuid.UUID]*storage.ReportMeta) (map[uuid.UUID]int32, error) {
+	reportIDs := make([]uuid.UUID, 0, len(myStatuses))
+	for _, ms := range myStatuses {
+		reportIDs = append(reportIDs, ms.ID)
+	}
+	byReport, err := s.repo.CountUnreadComments(ctx, teamID, reportIDs)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unread comments: %v", err)
+	}
+	res := map[uuid.UUID]int32{}
+	for incID, ms := range myStatuses {
+		if n := byReport[ms.ID]; n > 0 {
+			res[incID] = n
+		}
+	}
+	return res, nil
+}
+
+func toPBReportComment(c *storage.ReportComment) *pb.ReportComment {
+	res := &pb.ReportComment{
+		Id:           c.ID.String(),
+		ReportId:     c.ReportID.String(),
+		AuthorId:     c.AuthorID.String(),
+		AuthorTeamId: optionalUUIDString(c.AuthorTeamID),
+		FromJudge:    c.AuthorTeamID == nil,
+		Body:         c.Body,
+		CreatedAt:    c.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if c.StepNumber != nil {
+		res.StepNumber = *c.StepNumber
+	}
+	return res
+}
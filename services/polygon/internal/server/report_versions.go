@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *PolygonServer) ListReportVersions(ctx context.Context, req *pb.ListReportVersionsRequest) (*pb.ListReportVersionsResponse, error) {
+	reportID, err := parseReportID(req.GetReportId())
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.repo.ListReportVersions(ctx, reportID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list versions: %v", err)
+	}
+	if len(list) == 0 {
+		return nil, status.Error(codes.NotFound, "report not found")
+	}
+	resp := &pb.ListReportVersionsResponse{Versions: make([]*pb.ReportVersion, 0, len(list))}
+	for i := range list {
+		resp.Versions = append(resp.Versions, toPBReportVersion(&list[i]))
+	}
+	return resp, nil
+}
+
+func (s *PolygonServer) DiffReportVersions(ctx context.Context, req *pb.DiffReportVersionsRequest) (*pb.ReportVersionDiff, error) {
+	reportID, err := parseReportID(req.GetReportId())
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.repo.GetReportVersion(ctx, reportID, int32(req.GetToVersion()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "version not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get version: %v", err)
+	}
+	fromNumber := int32(req.GetFromVersion())
+	if fromNumber == 0 {
+		fromNumber = to.Number - 1
+	}
+	resp := &pb.ReportVersionDiff{ReportId: reportID.String(), ToVersion: uint32(to.Number)}
+	// У первой версии нет предыдущей — все её шаги считаются добавленными.
+	var fromSteps []storage.ReportStep
+	if fromNumber > 0 {
+		from, err := s.repo.GetReportVersion(ctx, reportID, fromNumber)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, status.Error(codes.NotFound, "version not found")
+			}
+			return nil, status.Errorf(codes.Internal, "get version: %v", err)
+		}
+		fromSteps = from.Steps
+		resp.FromVersion = uint32(from.Number)
+	}
+	resp.Steps = diffReportSteps(fromSteps, to.Steps)
+	return resp, nil
+}
+
+// diffReportSteps сопоставляет шаги двух версий по номеру.
+func diffReportSteps(before, after []storage.ReportStep) []*pb.ReportStepDiff {
+	byNumber := map[int32]*storage.ReportStep{}
+	var numbers []int32
+	for i := range before {
+		byNumber[before[i].Number] = &before[i]
+		numbers = append(numbers, before[i].Number)
+	}
+	afterByNumber := map[int32]*storage.ReportStep{}
+	for i := range after {
+		afterByNumber[after[i].Number] = &after[i]
+		if _, ok := byNumber[after[i].Number]; !ok {
+			numbers = append(numbers, after[i].Number)
+		}
+	}
+	res := make([]*pb.ReportStepDiff, 0, len(numbers))
+	for _, n := range numbers {
+		b, a := byNumber[n], afterByNumber[n]
+		d := &pb.ReportStepDiff{Number: uint32(n)}
+		switch {
+		case b == nil:
+			d.Change = pb.ReportStepChange_REPORT_STEP_CHANGE_ADDED
+			d.After = toPBReportStep(a)
+		case a == nil:
+			d.Change = pb.ReportStepChange_REPORT_STEP_CHANGE_REMOVED
+			d.Before = toPBReportStep(b)
+		default:
+			d.Before, d.After = toPBReportStep(b), toPBReportStep(a)
+			d.ChangedFields = changedStepFields(b, a)
+			d.Change = pb.ReportStepChange_REPORT_STEP_CHANGE_UNCHANGED
+			if len(d.ChangedFields) > 0 {
+				d.Change = pb.ReportStepChange_REPORT_STEP_CHANGE_MODIFIED
+			}
+		}
+		res = append(res, d)
+	}
+	return res
+}
+
+func changedStepFields(a, b *storage.ReportStep) []string {
+	var res []string
+	if a.Name != b.Name {
+		res = append(res, "name")
+	}
+	if a.Time != b.Time {
+		res = append(res, "time")
+	}
+	if a.Description != b.Description {
+		res = append(res, "description")
+	}
+	if a.Target != b.Target {
+		res = append(res, "target")
+	}
+	if a.Source != b.Source {
+		res = append(res, "source")
+	}
+	if a.Result != b.Result {
+		res = append(res, "result")
+	}
+	return res
+}
+
+func parseReportID(v string) (uuid.UUID, error) {
+	if strings.TrimSpace(v) == "" {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "report_id required")
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "invalid report_id")
+	}
+	return id, nil
+}
+
+func toPBReportStep(st *storage.ReportStep) *pb.ReportStep {
+	return &pb.ReportStep{
+		Id:          st.ID.String(),
+		Number:      uint32(st.Number),
+		Name:        st.Name,
+		Time:        uint32(st.Time),
+		Description: st.Description,
+		Target:      st.Target,
+		Source:      st.Source,
+		Result:      st.Result,
+	}
+}
+
+func toPBReportVersion(v *storage.ReportVersion) *pb.ReportVersion {
+	res := &pb.ReportVersion{
+		Id:              v.ID.String(),
+		ReportId:        v.ReportID.String(),
+		Number:          uint32(v.Number),
+		Time:            uint32(v.Time),
+		Status:          pb.ReportStatus(v.Status),
+		AwardedPercent:  v.AwardedPercent,
+		RejectionReason: v.RejectionReason,
+		ReviewedBy:      optionalUUIDString(v.ReviewedBy),
+		CreatedAt:       v.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if v.ReviewedAt != nil {
+		res.ReviewedAt = v.ReviewedAt.UTC().Format(time.RFC3339)
+	}
+	for i := range v.Steps {
+		res.Steps = append(res.Steps, toPBReportStep(&v.Steps[i]))
+	}
+	return res
+}
@@ -1,20 +1,16 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"errors"
-	"io"
 	"strings"
 	"time"
 
 	pb "gis/polygon/api/polygon/v1"
 	"gis/polygon/services/polygon/internal/storage"
 
-	gatewayfile "github.com/black-06/grpc-gateway-file"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	httpbody "google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -35,7 +31,8 @@ func (s *PolygonServer) SubmitReport(ctx context.Context, req *pb.SubmitReportRe
 		return nil, status.Error(codes.PermissionDenied, "no team")
 	}
 
-	if _, err := s.repo.GetIncident(ctx, incidentID); err != nil {
+	inc, err := s.repo.GetIncident(ctx, incidentID)
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, status.Error(codes.NotFound, "incident not found")
 		}
@@ -46,8 +43,24 @@ func (s *PolygonServer) SubmitReport(ctx context.Context, req *pb.SubmitReportRe
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "team: %v", err)
 	}
-	var redRef *uuid.UUID
+	ev, err := s.teamEvent(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+	if ev != nil {
+		if !ev.HasPolygon(inc.PolygonID) {
+			return nil, status.Error(codes.NotFound, "incident not in current event")
+		}
+		if !ev.Running(time.Now()) {
+			return nil, status.Error(codes.FailedPrecondition, "event is not running")
+		}
+	}
+	eventID := eventIDOf(ev)
+	var redRef, target *uuid.UUID
 	if tm.Type == int32(pb.TeamType_TEAM_TYPE_BLUE) {
+		if strings.TrimSpace(req.GetTargetTeamId()) != "" {
+			return nil, status.Error(codes.InvalidArgument, "target_team_id must be empty for blue team")
+		}
 		if strings.TrimSpace(req.GetRedTeamReportId()) == "" {
 			return nil, status.Error(codes.InvalidArgument, "red_team_report_id required for blue team")
 		}
@@ -72,17 +85,27 @@ func (s *PolygonServer) SubmitReport(ctx context.Context, req *pb.SubmitReportRe
 		if redTeam.Type != int32(pb.TeamType_TEAM_TYPE_RED) {
 			return nil, status.Error(codes.InvalidArgument, "referenced report is not red team report")
 		}
-		if pb.ReportStatus(rp.Status) != pb.ReportStatus_REPORT_STATUS_ACCEPTED {
-			return nil, status.Error(codes.InvalidArgument, "red_team_report must be ACCEPTED")
+		if !reportSolved(rp.Status) {
+			return nil, status.Error(codes.InvalidArgument, "red_team_report must be ACCEPTED or PARTIALLY_ACCEPTED")
+		}
+		if rp.TargetTeamID != nil && *rp.TargetTeamID != tid {
+			return nil, status.Error(codes.PermissionDenied, "red report targets another blue team")
+		}
+		if optionalUUIDString(rp.EventID) != optionalUUIDString(eventID) {
+			return nil, status.Error(codes.InvalidArgument, "red_team_report_id event mismatch")
 		}
 		redRef = &rid
 	} else {
 		if strings.TrimSpace(req.GetRedTeamReportId()) != "" {
 			return nil, status.Error(codes.InvalidArgument, "red_team_report_id must be empty for red team")
 		}
+		target, err = s.resolveReportTarget(ctx, inc.PolygonID, req.GetTargetTeamId())
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if exists, existingID, err := s.repo.ReportExistsForTeam(ctx, incidentID, tid); err == nil && exists {
+	if exists, existingID, err := s.repo.ReportExistsForTeam(ctx, incidentID, tid, target, eventID); err == nil && exists {
 
 		rp, err2 := s.repo.GetReport(ctx, existingID)
 		if err2 == nil {
@@ -95,12 +118,9 @@ func (s *PolygonServer) SubmitReport(ctx context.Context, req *pb.SubmitReportRe
 		steps = append(steps, storage.ReportStep{ID: uuid.New(), Number: int32(i + 1), Name: st.GetName(), Time: int32(st.GetTime()), Description: st.GetDescription(), Target: st.GetTarget(), Source: st.GetSource(), Result: st.GetResult()})
 	}
 	// time теперь unix timestamp момента отправки
-	if err := s.repo.InsertReport(ctx, reportID, incidentID, tid, redRef, int32(pb.ReportStatus_REPORT_STATUS_PENDING), int32(time.Now().Unix())); err != nil {
+	if err := s.repo.InsertReport(ctx, reportID, incidentID, tid, redRef, target, eventID, int32(pb.ReportStatus_REPORT_STATUS_PENDING), int32(time.Now().Unix()), steps); err != nil {
 		return nil, status.Errorf(codes.Internal, "insert report: %v", err)
 	}
-	if err := s.repo.InsertReportSteps(ctx, reportID, steps); err != nil {
-		return nil, status.Errorf(codes.Internal, "insert steps: %v", err)
-	}
 	rp, err := s.repo.GetReport(ctx, reportID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "reload: %v", err)
@@ -108,69 +128,39 @@ func (s *PolygonServer) SubmitReport(ctx context.Context, req *pb.SubmitReportRe
 	_ = userID
 	return s.toPBReport(ctx, rp), nil
 }
-func (s *PolygonServer) UploadReportAttachment(stream pb.PolygonClientService_UploadReportAttachmentServer) error {
-	formData, err := gatewayfile.NewFormData(stream, 50*1024*1024)
-	if err != nil {
-		return status.Errorf(codes.Internal, "form: %v", err)
-	}
-	defer formData.RemoveAll()
-	fileHeader := formData.FirstFile("file")
-	if fileHeader == nil {
-		return status.Error(codes.InvalidArgument, "file field required")
-	}
-	f, err := fileHeader.Open()
-	if err != nil {
-		return status.Errorf(codes.Internal, "open: %v", err)
-	}
-	defer f.Close()
-	buf := new(bytes.Buffer)
-	if _, err := io.Copy(buf, f); err != nil {
-		return status.Errorf(codes.Internal, "read: %v", err)
-	}
-	if buf.Len() == 0 {
-		return status.Error(codes.InvalidArgument, "empty file")
-	}
-	if s.s3 == nil {
-		return status.Error(codes.FailedPrecondition, "s3 not configured")
-	}
-	attID := uuid.New()
-	key := s.s3.ObjectKey("report_attachments", "1", attID.String())
-	_, size, err := s.s3.PutBytes(stream.Context(), key, buf.Bytes(), contentTypeOrDefault(fileHeader.Header.Get("Content-Type")))
-	if err != nil {
-		return status.Errorf(codes.Internal, "s3 put: %v", err)
-	}
 
-	return stream.SendAndClose(&pb.UploadReportAttachmentResponse{
-		Attachment: &pb.ReportAttachment{
-			Id:          attID.String(),
-			Url:         "/v1/report/attachments/" + attID.String(),
-			ContentType: contentTypeOrDefault(fileHeader.Header.Get("Content-Type")),
-			Size:        size,
-		},
-	})
+// reportSolved — принят ли отчёт полностью или частично (засчитывается как решение).
+func reportSolved(st int32) bool {
+	return pb.ReportStatus(st) == pb.ReportStatus_REPORT_STATUS_ACCEPTED || pb.ReportStatus(st) == pb.ReportStatus_REPORT_STATUS_PARTIALLY_ACCEPTED
 }
-func (s *PolygonServer) DownloadReportAttachment(req *pb.DownloadReportAttachmentRequest, stream pb.PolygonClientService_DownloadReportAttachmentServer) error {
-	if req.GetId() == "" {
-		return status.Error(codes.InvalidArgument, "id required")
-	}
-	id, err := uuid.Parse(req.GetId())
+
+// resolveReportTarget определяет копию полигона, которую атаковала красная команда.
+// Если у полигона одна синяя команда, цель можно не указывать; без синих команд цель не задаётся.
+func (s *PolygonServer) resolveReportTarget(ctx context.Context, polygonID uuid.UUID, targetTeamID string) (*uuid.UUID, error) {
+	blueTeams, err := s.repo.ListPolygonBlueTeams(ctx, polygonID)
 	if err != nil {
-		return status.Error(codes.InvalidArgument, "invalid id")
-	}
-	if s.s3 == nil {
-		return status.Error(codes.FailedPrecondition, "s3 not configured")
+		return nil, status.Errorf(codes.Internal, "polygon blue teams: %v", err)
+	}
+	if strings.TrimSpace(targetTeamID) == "" {
+		switch len(blueTeams) {
+		case 0:
+			return nil, nil
+		case 1:
+			return &blueTeams[0].ID, nil
+		default:
+			return nil, status.Error(codes.InvalidArgument, "target_team_id required")
+		}
 	}
-	key := s.s3.ObjectKey("report_attachments", "1", id.String())
-	obj, _, ct, err := s.s3.GetObject(stream.Context(), key)
+	id, err := uuid.Parse(targetTeamID)
 	if err != nil {
-		return status.Error(codes.NotFound, "attachment not found")
+		return nil, status.Error(codes.InvalidArgument, "invalid target_team_id")
 	}
-	defer obj.Close()
-	data, err := io.ReadAll(obj)
-	if err != nil {
-		return status.Errorf(codes.Internal, "read: %v", err)
+	for _, t := range blueTeams {
+		if t.ID == id {
+			return &id, nil
+		}
 	}
-	return stream.Send(&httpbody.HttpBody{ContentType: ct, Data: data})
+	return nil, status.Error(codes.InvalidArgument, "target team is not assigned to polygon")
 }
 
 func (s *PolygonServer) EditReport(ctx context.Context, req *pb.EditReportRequest) (*pb.Report, error) {
@@ -198,16 +188,26 @@ func (s *PolygonServer) EditReport(ctx context.Context, req *pb.EditReportReques
 	if pb.ReportStatus(rp.Status) != pb.ReportStatus_REPORT_STATUS_REJECTED {
 		return nil, status.Error(codes.FailedPrecondition, "only rejected can be edited")
 	}
+	if rp.EventID != nil {
+		ev, err := s.repo.GetEvent(ctx, *rp.EventID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "get event: %v", err)
+		}
+		if !ev.Running(time.Now()) {
+			return nil, status.Error(codes.FailedPrecondition, "event is not running")
+		}
+	}
 	steps := make([]storage.ReportStep, 0, len(req.GetSteps()))
 	for i, st := range req.GetSteps() {
 		steps = append(steps, storage.ReportStep{ID: uuid.New(), Number: int32(i + 1), Name: st.GetName(), Time: int32(st.GetTime()), Description: st.GetDescription(), Target: st.GetTarget(), Source: st.GetSource(), Result: st.GetResult()})
 	}
-	if err := s.repo.ReplaceReportSteps(ctx, reportID, steps); err != nil {
-		return nil, status.Errorf(codes.Internal, "replace: %v", err)
-	}
-	// При редактировании считаем отчёт новой версией: обновляем created_at и time
-	if err := s.repo.UpdateReportForEdit(ctx, reportID, int32(pb.ReportStatus_REPORT_STATUS_PENDING), int32(time.Now().Unix())); err != nil {
-		return nil, status.Errorf(codes.Internal, "status: %v", err)
+	// При редактировании считаем отчёт новой версией: обновляем created_at и time; предыдущие
+	// версии с шагами и причиной отклонения остаются в истории.
+	if err := s.repo.ResubmitReport(ctx, reportID, steps, int32(time.Now().Unix())); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.FailedPrecondition, "only rejected can be edited")
+		}
+		return nil, status.Errorf(codes.Internal, "resubmit: %v", err)
 	}
 	rp2, err := s.repo.GetReport(ctx, reportID)
 	if err != nil {
@@ -242,33 +242,76 @@ func (s *PolygonServer) GetIncidentReport(ctx context.Context, req *pb.GetIncide
 }
 
 func (s *PolygonServer) ReviewReport(ctx context.Context, req *pb.ReviewReportRequest) (*pb.Report, error) {
-	if req.GetReportId() == "" {
-		return nil, status.Error(codes.InvalidArgument, "report_id required")
+	reportID, d, err := parseReviewRequest(req)
+	if err != nil {
+		return nil, err
 	}
-	if req.GetStatus() != pb.ReportStatus_REPORT_STATUS_ACCEPTED && req.GetStatus() != pb.ReportStatus_REPORT_STATUS_REJECTED {
-		return nil, status.Error(codes.InvalidArgument, "status must be ACCEPTED or REJECTED")
+	var reviewer *uuid.UUID
+	if uid, _, err := s.extractAuth(ctx); err == nil {
+		if id, err := uuid.Parse(uid); err == nil {
+			reviewer = &id
+		}
 	}
-	if req.GetStatus() == pb.ReportStatus_REPORT_STATUS_REJECTED && strings.TrimSpace(req.GetReason()) == "" {
-		return nil, status.Error(codes.InvalidArgument, "reason required for rejection")
+	rp, err := s.repo.GetReport(ctx, reportID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "report not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get: %v", err)
 	}
-	reportID, err := uuid.Parse(req.GetReportId())
+	inc, err := s.repo.GetIncident(ctx, rp.IncidentID)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid report_id")
+		return nil, status.Errorf(codes.Internal, "get incident: %v", err)
+	}
+	// При коллегиальной проверке вердикты копятся, статус меняется, только когда политика даёт итог;
+	// захват отчёта одним судьёй не мешает голосовать остальным.
+	collegial := inc.Review.Mode != storage.ReviewPolicySingle
+	if collegial && reviewer == nil {
+		return nil, status.Error(codes.Unauthenticated, "judge id required")
+	}
+	if !collegial {
+		if err := s.checkReportClaim(ctx, reportID, reviewer); err != nil {
+			return nil, err
+		}
 	}
-	var reasonPtr *string
-	if req.GetStatus() == pb.ReportStatus_REPORT_STATUS_REJECTED {
-		r := req.GetReason()
-		reasonPtr = &r
+	if reviewer == nil {
+		err = s.repo.UpdateReportStatus(ctx, reportID, d.status, d.awarded, d.reason, nil)
+	} else {
+		_, err = s.repo.RecordReportVerdict(ctx, d.verdict(reportID, *reviewer, false), collegial,
+			func(verdicts []storage.ReportVerdict) (*storage.ReviewOutcome, bool) {
+				if !collegial {
+					return d.outcome(), false
+				}
+				final, disputed := decideReview(inc.Review, verdicts)
+				if final == nil {
+					return nil, disputed
+				}
+				return final.outcome(), false
+			})
 	}
-	if err := s.repo.UpdateReportStatus(ctx, reportID, int32(req.GetStatus()), reasonPtr); err != nil {
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "report not found")
+		}
+		if errors.Is(err, storage.ErrReportDecided) {
+			return nil, status.Error(codes.FailedPrecondition, "report already decided")
+		}
+		if errors.Is(err, storage.ErrReportClaimed) {
+			return nil, s.reportClaimedError(ctx, reportID)
+		}
 		return nil, status.Errorf(codes.Internal, "update: %v", err)
 	}
+	return s.reloadPBReport(ctx, reportID)
+}
+
+func (s *PolygonServer) reloadPBReport(ctx context.Context, reportID uuid.UUID) (*pb.Report, error) {
 	rp, err := s.repo.GetReport(ctx, reportID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "get: %v", err)
 	}
 	return s.toPBReport(ctx, rp), nil
 }
+
 func (s *PolygonServer) GetTeamReports(ctx context.Context, req *pb.GetTeamReportsRequest) (*pb.GetTeamReportsResponse, error) {
 	if req.GetTeamId() == "" {
 		return nil, status.Error(codes.InvalidArgument, "team_id required")
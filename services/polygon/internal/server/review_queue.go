@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (s *PolygonServer) ListReviewQueue(ctx context.Context, req *pb.ListReviewQueueRequest) (*pb.ListReviewQueueResponse, error) {
+	judgeID, err := s.callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	page := req.GetPage()
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.GetPageSize()
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	f := storage.ReviewQueueFilter{
+		MinAge: time.Duration(req.GetMinAgeSeconds()) * time.Second,
+		MaxAge: time.Duration(req.GetMaxAgeSeconds()) * time.Second,
+		Sort:   int32(req.GetSort()),
+		Limit:  pageSize,
+		Offset: (page - 1) * pageSize,
+	}
+	if f.EventID, err = parseOptionalUUID(req.GetEventId(), "event_id"); err != nil {
+		return nil, err
+	}
+	if f.PolygonID, err = parseOptionalUUID(req.GetPolygonId(), "polygon_id"); err != nil {
+		return nil, err
+	}
+	if f.IncidentID, err = parseOptionalUUID(req.GetIncidentId(), "incident_id"); err != nil {
+		return nil, err
+	}
+	if req.TeamType != nil {
+		tt := int32(req.GetTeamType())
+		f.TeamType = &tt
+	}
+	if !req.GetIncludeClaimed() {
+		f.HideClaimedFor = &judgeID
+	}
+	list, total, err := s.repo.ListReviewQueue(ctx, f)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "review queue: %v", err)
+	}
+	resp := &pb.ListReviewQueueResponse{Items: make([]*pb.ReviewQueueItem, 0, len(list)), Total: total, Page: page, PageSize: pageSize}
+	now := time.Now()
+	for _, e := range list {
+		rp, err := s.repo.GetReport(ctx, e.ReportID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return nil, status.Errorf(codes.Internal, "get: %v", err)
+		}
+		item := &pb.ReviewQueueItem{Report: s.toPBReport(ctx, rp), WaitingSeconds: uint32(now.Sub(e.CreatedAt).Seconds())}
+		if e.Claim != nil {
+			item.Claim = toPBReportClaim(e.Claim)
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	return resp, nil
+}
+
+func (s *PolygonServer) ClaimReport(ctx context.Context, req *pb.ClaimReportRequest) (*pb.ReportClaim, error) {
+	reportID, err := parseReportID(req.GetReportId())
+	if err != nil {
+		return nil, err
+	}
+	judgeID, err := s.callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.repo.ClaimReport(ctx, reportID, judgeID, s.reviewClaimTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrReportClaimed):
+			return nil, claimedError(c)
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, status.Error(codes.NotFound, "pending report not found")
+		}
+		return nil, status.Errorf(codes.Internal, "claim: %v", err)
+	}
+	return toPBReportClaim(c), nil
+}
+
+func (s *PolygonServer) ReleaseReport(ctx context.Context, req *pb.ClaimReportRequest) (*emptypb.Empty, error) {
+	reportID, err := parseReportID(req.GetReportId())
+	if err != nil {
+		return nil, err
+	}
+	judgeID, err := s.callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReleaseReportClaim(ctx, reportID, &judgeID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "claim not found")
+		}
+		return nil, status.Errorf(codes.Internal, "release: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// checkReportClaim — отчёт не закреплён за другим судьёй.
+func (s *PolygonServer) checkReportClaim(ctx context.Context, reportID uuid.UUID, judgeID *uuid.UUID) error {
+	c, err := s.repo.GetReportClaim(ctx, reportID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return status.Errorf(codes.Internal, "get claim: %v", err)
+	}
+	if judgeID != nil && c.JudgeID == *judgeID {
+		return nil
+	}
+	return claimedError(c)
+}
+
+// reportClaimedError — ошибка для storage.ErrReportClaimed, с текущим захватом, если он ещё действует.
+func (s *PolygonServer) reportClaimedError(ctx context.Context, reportID uuid.UUID) error {
+	c, err := s.repo.GetReportClaim(ctx, reportID)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, storage.ErrReportClaimed.Error())
+	}
+	return claimedError(c)
+}
+
+func claimedError(c *storage.ReportClaim) error {
+	return status.Errorf(codes.FailedPrecondition, "report claimed by %s until %s", c.JudgeID, c.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// callerUserID — id пользователя из метаданных запроса.
+func (s *PolygonServer) callerUserID(ctx context.Context) (uuid.UUID, error) {
+	uid, _, err := s.extractAuth(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id, err := uuid.Parse(uid)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "invalid user id")
+	}
+	return id, nil
+}
+
+// parseOptionalUUID разбирает необязательный id фильтра; пустая строка — nil.
+func parseOptionalUUID(v, field string) (*uuid.UUID, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid "+field)
+	}
+	return &id, nil
+}
+
+func toPBReportClaim(c *storage.ReportClaim) *pb.ReportClaim {
+	return &pb.ReportClaim{
+		ReportId:  c.ReportID.String(),
+		JudgeId:   c.JudgeID.String(),
+		ClaimedAt: c.ClaimedAt.UTC().Format(time.RFC3339),
+		ExpiresAt: c.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
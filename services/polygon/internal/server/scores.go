@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// scoreCutoff — момент, до которого клиентам показываются очки: момент заморозки скорборда,
+// либо nil, если заморозки нет или запрос от администратора.
+func (s *PolygonServer) scoreCutoff(ctx context.Context) (*time.Time, error) {
+	if isAdminCaller(ctx) {
+		return nil, nil
+	}
+	return s.repo.GetScoreboardFreeze(ctx)
+}
+
+func formatCutoff(cutoff *time.Time) string {
+	if cutoff == nil {
+		return ""
+	}
+	return cutoff.UTC().Format(time.RFC3339)
+}
+
+// visibleFines оставляет штрафы соревнования scope и скрывает от клиента штрафы, выданные
+// после заморозки, и их отзыв после неё.
+func visibleFines(fines []storage.TeamFine, scope storage.ScoreScope) []storage.TeamFine {
+	if scope.Until == nil && scope.EventID == nil {
+		return fines
+	}
+	res := make([]storage.TeamFine, 0, len(fines))
+	for _, f := range fines {
+		if scope.EventID != nil && (f.EventID == nil || *f.EventID != *scope.EventID) {
+			continue
+		}
+		if scope.Until != nil {
+			if !f.CreatedAt.Before(*scope.Until) {
+				continue
+			}
+			if f.RevokedAt != nil && !f.RevokedAt.Before(*scope.Until) {
+				f.RevokedAt = nil
+			}
+		}
+		res = append(res, f)
+	}
+	return res
+}
+
+func (s *PolygonServer) GetScores(ctx context.Context, req *pb.GetScoresRequest) (*pb.GetScoresResponse, error) {
+	scope, err := s.scoreScope(ctx, req.GetEventId())
+	if err != nil {
+		return nil, err
+	}
+	teams, err := s.scopeTeams(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	prizes, err := s.repo.ListTeamPrizes(ctx, scope)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "team prizes: %v", err)
+	}
+	reportCounts, err := s.repo.ListTeamReportCounts(ctx, scope)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "team report counts: %v", err)
+	}
+	resp := &pb.GetScoresResponse{Scores: make([]*pb.TeamScore, 0, len(teams)), FrozenAt: formatCutoff(scope.Until), EventId: optionalUUIDString(scope.EventID)}
+	for _, t := range teams {
+		sc := &pb.TeamScore{
+			TeamId:     t.ID.String(),
+			TeamName:   t.Name,
+			PrizeTotal: prizes[t.ID] + t.InitialPrize,
+		}
+		if rc, ok := reportCounts[t.ID]; ok {
+			sc.SubmittedIncidents = rc[0]
+			sc.AcceptedIncidents = rc[1]
+		}
+		resp.Scores = append(resp.Scores, sc)
+	}
+	sort.SliceStable(resp.Scores, func(i, j int) bool {
+		a, b := resp.Scores[i], resp.Scores[j]
+		if a.PrizeTotal != b.PrizeTotal {
+			return a.PrizeTotal > b.PrizeTotal
+		}
+		return a.TeamName < b.TeamName
+	})
+	return resp, nil
+}
+
+func (s *PolygonServer) GetScoreHistory(ctx context.Context, req *pb.GetScoresRequest) (*pb.GetScoreHistoryResponse, error) {
+	scope, err := s.scoreScope(ctx, req.GetEventId())
+	if err != nil {
+		return nil, err
+	}
+	teams, err := s.scopeTeams(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.repo.ListScoreEvents(ctx, scope)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "score events: %v", err)
+	}
+	resp := &pb.GetScoreHistoryResponse{Teams: make([]*pb.TeamScoreHistory, 0, len(teams)), FrozenAt: formatCutoff(scope.Until), EventId: optionalUUIDString(scope.EventID)}
+	byTeam := make(map[uuid.UUID]*pb.TeamScoreHistory, len(teams))
+	for _, t := range teams {
+		h := &pb.TeamScoreHistory{TeamId: t.ID.String(), TeamName: t.Name}
+		byTeam[t.ID] = h
+		resp.Teams = append(resp.Teams, h)
+	}
+	score := make(map[uuid.UUID]int64, len(teams))
+	for _, e := range events {
+		h, ok := byTeam[e.TeamID]
+		if !ok {
+			continue
+		}
+		score[e.TeamID] += e.Delta
+		ts := e.Time.Unix()
+		// Изменения в одну и ту же секунду схлопываем в одну точку.
+		if n := len(h.Points); n > 0 && h.Points[n-1].Time == ts {
+			h.Points[n-1].Score = score[e.TeamID]
+			continue
+		}
+		h.Points = append(h.Points, &pb.ScorePoint{Time: ts, Score: score[e.TeamID]})
+	}
+	return resp, nil
+}
+
+// scopeTeams — команды скорборда: участники соревнования scope либо все команды.
+func (s *PolygonServer) scopeTeams(ctx context.Context, scope storage.ScoreScope) ([]storage.Team, error) {
+	teams, err := s.repo.ListTeams(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list teams: %v", err)
+	}
+	filter, err := s.eventTeamFilter(ctx, scope)
+	if err != nil || filter == nil {
+		return teams, err
+	}
+	res := make([]storage.Team, 0, len(filter))
+	for _, t := range teams {
+		if filter[t.ID] {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func (s *PolygonServer) GetScoreboardFreeze(ctx context.Context, _ *emptypb.Empty) (*pb.ScoreboardFreeze, error) {
+	frozenAt, err := s.repo.GetScoreboardFreeze(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get freeze: %v", err)
+	}
+	return toPBScoreboardFreeze(frozenAt), nil
+}
+
+func (s *PolygonServer) SetScoreboardFreeze(ctx context.Context, req *pb.SetScoreboardFreezeRequest) (*pb.ScoreboardFreeze, error) {
+	frozenAt := time.Now()
+	if v := strings.TrimSpace(req.GetFrozenAt()); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid frozen_at")
+		}
+		frozenAt = t
+	}
+	if err := s.repo.SetScoreboardFreeze(ctx, &frozenAt); err != nil {
+		return nil, status.Errorf(codes.Internal, "set freeze: %v", err)
+	}
+	return toPBScoreboardFreeze(&frozenAt), nil
+}
+
+func (s *PolygonServer) RevealScoreboard(ctx context.Context, _ *emptypb.Empty) (*pb.ScoreboardFreeze, error) {
+	if err := s.repo.SetScoreboardFreeze(ctx, nil); err != nil {
+		return nil, status.Errorf(codes.Internal, "reveal: %v", err)
+	}
+	return &pb.ScoreboardFreeze{}, nil
+}
+
+func toPBScoreboardFreeze(frozenAt *time.Time) *pb.ScoreboardFreeze {
+	if frozenAt == nil {
+		return &pb.ScoreboardFreeze{}
+	}
+	return &pb.ScoreboardFreeze{FrozenAt: formatCutoff(frozenAt), Frozen: !time.Now().Before(*frozenAt)}
+}
+
+func (s *PolygonServer) ListTeamScoreLedger(ctx context.Context, req *pb.ListTeamScoreLedgerRequest) (*pb.ListTeamScoreLedgerResponse, error) {
+	if strings.TrimSpace(req.GetTeamId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "team_id required")
+	}
+	tid, err := uuid.Parse(req.GetTeamId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid team_id")
+	}
+	list, err := s.repo.ListTeamLedger(ctx, tid)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list ledger: %v", err)
+	}
+	resp := &pb.ListTeamScoreLedgerResponse{Entries: make([]*pb.ScoreLedgerEntry, 0, len(list))}
+	for i := range list {
+		resp.Entries = append(resp.Entries, toPBLedgerEntry(&list[i]))
+	}
+	return resp, nil
+}
+
+func (s *PolygonServer) CreateScoreAdjustment(ctx context.Context, req *pb.CreateScoreAdjustmentRequest) (*pb.ScoreLedgerEntry, error) {
+	if strings.TrimSpace(req.GetTeamId()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "team_id required")
+	}
+	if req.GetAmount() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount must be non-zero")
+	}
+	if strings.TrimSpace(req.GetReason()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason required")
+	}
+	tid, err := uuid.Parse(req.GetTeamId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid team_id")
+	}
+	if _, err := s.repo.GetTeam(ctx, tid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "team not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get team: %v", err)
+	}
+	eventID, err := s.resolveEventID(ctx, req.GetEventId(), tid)
+	if err != nil {
+		return nil, err
+	}
+	e := &storage.LedgerEntry{TeamID: tid, Amount: req.GetAmount(), Reason: strings.TrimSpace(req.GetReason()), EventID: eventID}
+	if uid, _, err := s.extractAuth(ctx); err == nil {
+		if id, err := uuid.Parse(uid); err == nil {
+			e.CreatedBy = &id
+		}
+	}
+	if err := s.repo.CreateLedgerAdjustment(ctx, e); err != nil {
+		return nil, status.Errorf(codes.Internal, "create adjustment: %v", err)
+	}
+	return toPBLedgerEntry(e), nil
+}
+
+func toPBLedgerEntry(e *storage.LedgerEntry) *pb.ScoreLedgerEntry {
+	res := &pb.ScoreLedgerEntry{
+		Id:        e.ID.String(),
+		TeamId:    e.TeamID.String(),
+		Kind:      pb.ScoreLedgerKind(e.Kind),
+		Amount:    e.Amount,
+		Reason:    e.Reason,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339),
+		EventId:   optionalUUIDString(e.EventID),
+	}
+	if e.IncidentID != nil {
+		res.IncidentId = e.IncidentID.String()
+	}
+	if e.ReportID != nil {
+		res.ReportId = e.ReportID.String()
+	}
+	if e.FineID != nil {
+		res.FineId = e.FineID.String()
+	}
+	if e.CreatedBy != nil {
+		res.CreatedBy = e.CreatedBy.String()
+	}
+	return res
+}
@@ -6,7 +6,10 @@ import (
 	"net"
 	"os"
 	"strings"
+	"time"
 
+	externalv1 "gis/polygon/api/external/v1"
+	labv1 "gis/polygon/api/lab/v1"
 	pb "gis/polygon/api/polygon/v1"
 	upb "gis/polygon/api/users/v1"
 	"gis/polygon/services/polygon/internal/media"
@@ -22,11 +25,18 @@ import (
 type PolygonServer struct {
 	pb.UnimplementedPolygonClientServiceServer
 	pb.UnimplementedPolygonAdminServiceServer
+	labv1.UnimplementedLabClientServiceServer
+	labv1.UnimplementedLabAdminServiceServer
 	repo             *storage.Repo
 	s3               *media.S3Storage
 	jwtSecret        []byte
 	usersClient      upb.UsersClientServiceClient
 	usersAdminClient upb.UsersAdminServiceClient
+	externalClient   externalv1.ExternalControllerServiceClient
+	// attachmentLinkTTL — срок действия подписанных ссылок на вложения отчётов.
+	attachmentLinkTTL time.Duration
+	// reviewClaimTTL — на сколько судья закрепляет за собой отчёт из очереди проверки.
+	reviewClaimTTL time.Duration
 }
 
 func RunGRPC(addr string) error {
@@ -39,6 +49,33 @@ func RunGRPC(addr string) error {
 	if err := repo.Migrate(context.Background()); err != nil {
 		return err
 	}
+	if err := repo.MigrateEvents(context.Background()); err != nil {
+		return err
+	}
+	if err := repo.MigrateRanges(context.Background()); err != nil {
+		return err
+	}
+	if err := repo.MigrateReportVersions(context.Background()); err != nil {
+		return err
+	}
+	if err := repo.MigrateAttachments(context.Background()); err != nil {
+		return err
+	}
+	if err := repo.MigrateReviewQueue(context.Background()); err != nil {
+		return err
+	}
+	if err := repo.MigrateVerdicts(context.Background()); err != nil {
+		return err
+	}
+	if err := repo.MigrateLedger(context.Background()); err != nil {
+		return err
+	}
+	if err := repo.MigrateComments(context.Background()); err != nil {
+		return err
+	}
+	if err := repo.MigrateAppeals(context.Background()); err != nil {
+		return err
+	}
 	if err := repo.MigrateLabs(context.Background()); err != nil {
 		log.Printf("labs migration error: %v", err)
 	}
@@ -71,9 +108,43 @@ func RunGRPC(addr string) error {
 			usersAdm = upb.NewUsersAdminServiceClient(conn)
 		}
 	}
-	srv := &PolygonServer{repo: repo, s3: s3, jwtSecret: jwtSecret, usersClient: usersCl, usersAdminClient: usersAdm}
+	externalAddr := getenv("EXTERNAL_CONTROLLER_GRPC_ADDR", "")
+	var externalCl externalv1.ExternalControllerServiceClient
+	if externalAddr != "" {
+		conn, err := grpc.Dial(externalAddr, grpc.WithInsecure())
+		if err != nil {
+			log.Printf("external controller dial failed: %v", err)
+		} else {
+			externalCl = externalv1.NewExternalControllerServiceClient(conn)
+		}
+	}
+	linkTTL, err := time.ParseDuration(getenv("POLYGON_ATTACHMENT_LINK_TTL", "5m"))
+	if err != nil || linkTTL <= 0 {
+		linkTTL = 5 * time.Minute
+	}
+	claimTTL, err := time.ParseDuration(getenv("POLYGON_REVIEW_CLAIM_TTL", "15m"))
+	if err != nil || claimTTL <= 0 {
+		claimTTL = 15 * time.Minute
+	}
+	srv := &PolygonServer{repo: repo, s3: s3, jwtSecret: jwtSecret, usersClient: usersCl, usersAdminClient: usersAdm, externalClient: externalCl, attachmentLinkTTL: linkTTL, reviewClaimTTL: claimTTL}
+	if externalCl != nil {
+		interval, err := time.ParseDuration(getenv("POLYGON_LAB_PROVISION_INTERVAL", "30s"))
+		if err != nil || interval <= 0 {
+			interval = 30 * time.Second
+		}
+		go srv.runLabProvisioner(context.Background(), interval)
+	}
+	if s3 != nil {
+		interval, err := time.ParseDuration(getenv("POLYGON_ATTACHMENT_SWEEP_INTERVAL", "1h"))
+		if err != nil || interval <= 0 {
+			interval = time.Hour
+		}
+		go srv.runAttachmentSweeper(context.Background(), interval)
+	}
 	pb.RegisterPolygonClientServiceServer(grpcServer, srv)
 	pb.RegisterPolygonAdminServiceServer(grpcServer, srv)
+	labv1.RegisterLabClientServiceServer(grpcServer, srv)
+	labv1.RegisterLabAdminServiceServer(grpcServer, srv)
 	log.Printf("polygon gRPC listening on %s", addr)
 	return grpcServer.Serve(lis)
 }
@@ -98,6 +169,15 @@ func (s *PolygonServer) extractAuth(ctx context.Context) (string, string, error)
 	return uid, team, nil
 }
 
+// isAdminCaller — запрос пришёл от администратора (роль проставляет gateway).
+func isAdminCaller(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	return firstNonEmpty(md.Get("x-user-role")) == "admin"
+}
+
 func firstNonEmpty(vals []string) string {
 	for _, v := range vals {
 		if strings.TrimSpace(v) != "" {
@@ -112,8 +192,8 @@ func (s *PolygonServer) toPBReport(ctx context.Context, r *storage.Report) *pb.R
 		return nil
 	}
 	pbSteps := make([]*pb.ReportStep, 0, len(r.Steps))
-	for _, s := range r.Steps {
-		pbSteps = append(pbSteps, &pb.ReportStep{Id: s.ID.String(), Number: uint32(s.Number), Name: s.Name, Time: uint32(s.Time), Description: s.Description, Target: s.Target, Source: s.Source, Result: s.Result})
+	for i := range r.Steps {
+		pbSteps = append(pbSteps, toPBReportStep(&r.Steps[i]))
 	}
 	var redRef string
 	if r.RedTeamReportID != nil {
@@ -139,6 +219,10 @@ func (s *PolygonServer) toPBReport(ctx context.Context, r *storage.Report) *pb.R
 			}
 		}
 	}
+	var targetRef string
+	if r.TargetTeamID != nil {
+		targetRef = r.TargetTeamID.String()
+	}
 	var incidentName, polygonName string
 	if in, err := s.repo.GetIncident(ctx, r.IncidentID); err == nil && in != nil {
 		incidentName = in.Name
@@ -146,7 +230,13 @@ func (s *PolygonServer) toPBReport(ctx context.Context, r *storage.Report) *pb.R
 	if pn, err := s.repo.GetIncidentPolygonName(ctx, r.IncidentID); err == nil {
 		polygonName = pn
 	}
-	return &pb.Report{Id: r.ID.String(), IncidentId: r.IncidentID.String(), IncidentName: incidentName, PolygonName: polygonName, Team: teamPB, Steps: pbSteps, Time: uint32(r.Time), Status: pb.ReportStatus(r.Status), RejectionReason: r.RejectionReason, RedTeamReportId: redRef}
+	var attachments []*pb.ReportAttachment
+	if list, err := s.repo.ListReportAttachments(ctx, r.ID); err == nil {
+		for i := range list {
+			attachments = append(attachments, toPBReportAttachment(&list[i]))
+		}
+	}
+	return &pb.Report{Id: r.ID.String(), IncidentId: r.IncidentID.String(), IncidentName: incidentName, PolygonName: polygonName, Team: teamPB, Steps: pbSteps, Time: uint32(r.Time), Status: pb.ReportStatus(r.Status), AwardedPercent: r.AwardedPercent, RejectionReason: r.RejectionReason, RedTeamReportId: redRef, TargetTeamId: targetRef, EventId: optionalUUIDString(r.EventID), Attachments: attachments}
 }
 
 func derefOr(p *string, def string) string {
@@ -23,14 +23,22 @@ func (s *PolygonServer) GetTeams(ctx context.Context, _ *emptypb.Empty) (*pb.Get
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "list teams: %v", err)
 	}
-	prizes, err := s.repo.ListTeamPrizes(ctx)
+	scope, err := s.scoreScope(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	prizes, err := s.repo.ListTeamPrizes(ctx, scope)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "team prizes: %v", err)
 	}
-	reportCounts, err := s.repo.ListTeamReportCounts(ctx)
+	reportCounts, err := s.repo.ListTeamReportCounts(ctx, scope)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "team report counts: %v", err)
 	}
+	bonuses, err := s.repo.ListTeamBonusTotals(ctx, scope)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "team bonuses: %v", err)
+	}
 	resp := &pb.GetTeamsResponse{}
 
 	if s.usersAdminClient == nil {
@@ -46,7 +54,9 @@ func (s *PolygonServer) GetTeams(ctx context.Context, _ *emptypb.Empty) (*pb.Get
 				pbTeam.ReportsSubmitted = rc[0]
 				pbTeam.ReportsAccepted = rc[1]
 			}
+			pbTeam.BonusTotal = bonuses[t.ID]
 			if fines, err2 := s.repo.ListTeamFines(ctx, t.ID); err2 == nil {
+				fines = visibleFines(fines, scope)
 				for i := range fines {
 					pbTeam.Fines = append(pbTeam.Fines, toPBTeamFine(&fines[i]))
 				}
@@ -102,7 +112,9 @@ func (s *PolygonServer) GetTeams(ctx context.Context, _ *emptypb.Empty) (*pb.Get
 			pbTeam.ReportsSubmitted = rc[0]
 			pbTeam.ReportsAccepted = rc[1]
 		}
+		pbTeam.BonusTotal = bonuses[t.ID]
 		if fines, err2 := s.repo.ListTeamFines(ctx, t.ID); err2 == nil {
+			fines = visibleFines(fines, scope)
 			for i := range fines {
 				pbTeam.Fines = append(pbTeam.Fines, toPBTeamFine(&fines[i]))
 			}
@@ -164,7 +176,7 @@ func (s *PolygonServer) EditTeam(ctx context.Context, req *pb.EditTeamRequest) (
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "get: %v", err)
 	}
-	prizes, _ := s.repo.ListTeamPrizes(ctx) // ignore error, best-effort
+	prizes, _ := s.repo.ListTeamPrizes(ctx, storage.ScoreScope{}) // ignore error, best-effort
 	var prizeTotal int64
 	if v, ok := prizes[st.ID]; ok {
 		prizeTotal = v + st.InitialPrize
@@ -242,19 +254,32 @@ func (s *PolygonServer) GetUserTeam(ctx context.Context, req *pb.GetUserTeamRequ
 		}
 		return nil, status.Errorf(codes.Internal, "get: %v", err)
 	}
+	cutoff, err := s.scoreCutoff(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "scoreboard freeze: %v", err)
+	}
+	ev, err := s.teamEvent(ctx, team.ID)
+	if err != nil {
+		return nil, err
+	}
+	scope := storage.ScoreScope{EventID: eventIDOf(ev), Until: cutoff}
 	pbTeam := &pb.Team{Id: team.ID.String(), Name: team.Name, Type: pb.TeamType(team.Type), InitialPrize: team.InitialPrize}
 	if fines, err2 := s.repo.ListTeamFines(ctx, team.ID); err2 == nil {
+		fines = visibleFines(fines, scope)
 		for i := range fines {
 			pbTeam.Fines = append(pbTeam.Fines, toPBTeamFine(&fines[i]))
 		}
 	}
-	prizes, _ := s.repo.ListTeamPrizes(ctx)
+	prizes, _ := s.repo.ListTeamPrizes(ctx, scope)
 	if v, ok := prizes[team.ID]; ok {
 		pbTeam.PrizeTotal = v + team.InitialPrize
 	} else {
 		pbTeam.PrizeTotal = team.InitialPrize
 	}
-	if rcMap, err2 := s.repo.ListTeamReportCounts(ctx); err2 == nil {
+	if bonuses, err2 := s.repo.ListTeamBonusTotals(ctx, scope); err2 == nil {
+		pbTeam.BonusTotal = bonuses[team.ID]
+	}
+	if rcMap, err2 := s.repo.ListTeamReportCounts(ctx, scope); err2 == nil {
 		if rc, ok := rcMap[team.ID]; ok {
 			pbTeam.ReportsSubmitted = rc[0]
 			pbTeam.ReportsAccepted = rc[1]
@@ -284,8 +309,12 @@ func (s *PolygonServer) CreateTeamFine(ctx context.Context, req *pb.CreateTeamFi
 		}
 		return nil, status.Errorf(codes.Internal, "get team: %v", err)
 	}
+	eventID, err := s.resolveEventID(ctx, req.GetEventId(), tid)
+	if err != nil {
+		return nil, err
+	}
 	id := uuid.New()
-	if err := s.repo.CreateTeamFine(ctx, id, tid, req.GetAmount(), strings.TrimSpace(req.GetReason())); err != nil {
+	if err := s.repo.CreateTeamFine(ctx, id, tid, eventID, req.GetAmount(), strings.TrimSpace(req.GetReason())); err != nil {
 		return nil, status.Errorf(codes.Internal, "create fine: %v", err)
 	}
 	// Возвращаем полный объект
@@ -302,7 +331,7 @@ func (s *PolygonServer) CreateTeamFine(ctx context.Context, req *pb.CreateTeamFi
 	}
 	if created == nil {
 		// fallback
-		created = &storage.TeamFine{ID: id, TeamID: tid, Amount: req.GetAmount(), Reason: req.GetReason()}
+		created = &storage.TeamFine{ID: id, TeamID: tid, EventID: eventID, Amount: req.GetAmount(), Reason: req.GetReason()}
 	}
 	return toPBTeamFine(created), nil
 }
@@ -358,5 +387,6 @@ func toPBTeamFine(f *storage.TeamFine) *pb.TeamFine {
 		Reason:    f.Reason,
 		CreatedAt: f.CreatedAt.UTC().Format(time.RFC3339),
 		RevokedAt: revoked,
+		EventId:   optionalUUIDString(f.EventID),
 	}
 }
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	pb "gis/polygon/api/polygon/v1"
+	"gis/polygon/services/polygon/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// reviewDecision — итог проверки в виде аргументов UpdateReportStatus.
+type reviewDecision struct {
+	status  int32
+	awarded *int32  // nil — не принят
+	reason  *string // только при REJECTED
+}
+
+func (d reviewDecision) verdict(reportID, judgeID uuid.UUID, head bool) *storage.ReportVerdict {
+	v := &storage.ReportVerdict{ReportID: reportID, JudgeID: judgeID, Status: d.status, Head: head}
+	if d.awarded != nil {
+		v.AwardedPercent = *d.awarded
+	}
+	if d.reason != nil {
+		v.Reason = *d.reason
+	}
+	return v
+}
+
+func (d reviewDecision) outcome() *storage.ReviewOutcome {
+	return &storage.ReviewOutcome{Status: d.status, AwardedPercent: d.awarded, Reason: d.reason}
+}
+
+// parseReviewRequest проверяет статус, долю и причину запроса проверки.
+func parseReviewRequest(req *pb.ReviewReportRequest) (uuid.UUID, reviewDecision, error) {
+	var d reviewDecision
+	if req.GetReportId() == "" {
+		return uuid.Nil, d, status.Error(codes.InvalidArgument, "report_id required")
+	}
+	switch req.GetStatus() {
+	case pb.ReportStatus_REPORT_STATUS_ACCEPTED:
+		full := int32(100)
+		d.awarded = &full
+	case pb.ReportStatus_REPORT_STATUS_PARTIALLY_ACCEPTED:
+		p := req.GetAwardedPercent()
+		if p <= 0 || p >= 100 {
+			return uuid.Nil, d, status.Error(codes.InvalidArgument, "awarded_percent must be in 1..99 for partial acceptance")
+		}
+		d.awarded = &p
+	case pb.ReportStatus_REPORT_STATUS_REJECTED:
+		if strings.TrimSpace(req.GetReason()) == "" {
+			return uuid.Nil, d, status.Error(codes.InvalidArgument, "reason required for rejection")
+		}
+		r := req.GetReason()
+		d.reason = &r
+	default:
+		return uuid.Nil, d, status.Error(codes.InvalidArgument, "status must be ACCEPTED, PARTIALLY_ACCEPTED or REJECTED")
+	}
+	d.status = int32(req.GetStatus())
+	reportID, err := uuid.Parse(req.GetReportId())
+	if err != nil {
+		return uuid.Nil, d, status.Error(codes.InvalidArgument, "invalid report_id")
+	}
+	return reportID, d, nil
+}
+
+// decideReview выводит итог проверки из вердиктов по политике инцидента. nil — итога ещё нет;
+// disputed — судьи разошлись и решение за главным судьёй.
+func decideReview(p storage.IncidentReviewPolicy, verdicts []storage.ReportVerdict) (*reviewDecision, bool) {
+	var approvals, rejections []storage.ReportVerdict
+	for _, v := range verdicts {
+		if reportSolved(v.Status) {
+			approvals = append(approvals, v)
+		} else {
+			rejections = append(rejections, v)
+		}
+	}
+	need := int(p.Judges)
+	if need < 1 {
+		need = 1
+	}
+	switch p.Mode {
+	case storage.ReviewPolicyNApprovals:
+		if len(approvals) > 0 && len(rejections) > 0 {
+			return nil, true
+		}
+		if len(approvals) >= need {
+			return approveDecision(approvals), false
+		}
+		if len(rejections) >= need {
+			return rejectDecision(rejections), false
+		}
+	case storage.ReviewPolicyMajority:
+		if len(approvals) > need/2 {
+			return approveDecision(approvals), false
+		}
+		if len(rejections) > need/2 {
+			return rejectDecision(rejections), false
+		}
+		if len(verdicts) >= need {
+			return nil, true
+		}
+	default:
+		if n := len(verdicts); n > 0 {
+			if reportSolved(verdicts[n-1].Status) {
+				return approveDecision(verdicts[n-1:]), false
+			}
+			return rejectDecision(verdicts[n-1:]), false
+		}
+	}
+	return nil, false
+}
+
+// approveDecision — принятие в наименьшей из одобренных долей.
+func approveDecision(approvals []storage.ReportVerdict) *reviewDecision {
+	pct := int32(100)
+	for _, v := range approvals {
+		if v.AwardedPercent > 0 && v.AwardedPercent < pct {
+			pct = v.AwardedPercent
+		}
+	}
+	d := &reviewDecision{status: int32(pb.ReportStatus_REPORT_STATUS_ACCEPTED), awarded: &pct}
+	if pct < 100 {
+		d.status = int32(pb.ReportStatus_REPORT_STATUS_PARTIALLY_ACCEPTED)
+	}
+	return d
+}
+
+// rejectDecision — отклонение с причинами всех отклонивших судей.
+func rejectDecision(rejections []storage.ReportVerdict) *reviewDecision {
+	seen := map[string]bool{}
+	var reasons []string
+	for _, v := range rejections {
+		r := strings.TrimSpace(v.Reason)
+		if r == "" || seen[r] {
+			continue
+		}
+		seen[r] = true
+		reasons = append(reasons, r)
+	}
+	reason := strings.Join(reasons, "; ")
+	return &reviewDecision{status: int32(pb.ReportStatus_REPORT_STATUS_REJECTED), reason: &reason}
+}
+
+func (s *PolygonServer) GetReportReview(ctx context.Context, req *pb.GetReportReviewRequest) (*pb.ReportReviewState, error) {
+	reportID, err := parseReportID(req.GetReportId())
+	if err != nil {
+		return nil, err
+	}
+	rp, err := s.repo.GetReport(ctx, reportID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "report not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get: %v", err)
+	}
+	return s.reportReviewState(ctx, rp)
+}
+
+func (s *PolygonServer) ListReviewDisputes(ctx context.Context, req *pb.ListReviewDisputesRequest) (*pb.ListReviewDisputesResponse, error) {
+	eventID, err := parseOptionalUUID(req.GetEventId(), "event_id")
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.repo.ListDisputedReports(ctx, eventID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list disputes: %v", err)
+	}
+	resp := &pb.ListReviewDisputesResponse{Disputes: make([]*pb.ReviewDispute, 0, len(ids))}
+	for _, id := range ids {
+		rp, err := s.repo.GetReport(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return nil, status.Errorf(codes.Internal, "get: %v", err)
+		}
+		st, err := s.reportReviewState(ctx, rp)
+		if err != nil {
+			return nil, err
+		}
+		resp.Disputes = append(resp.Disputes, &pb.ReviewDispute{Report: s.toPBReport(ctx, rp), Review: st})
+	}
+	return resp, nil
+}
+
+func (s *PolygonServer) ResolveReviewDispute(ctx context.Context, req *pb.ReviewReportRequest) (*pb.Report, error) {
+	reportID, d, err := parseReviewRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	judgeID, err := s.callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := s.repo.GetReport(ctx, reportID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "report not found")
+		}
+		return nil, status.Errorf(codes.Internal, "get: %v", err)
+	}
+	inc, err := s.repo.GetIncident(ctx, rp.IncidentID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get incident: %v", err)
+	}
+	if inc.Review.HeadJudgeID != nil && *inc.Review.HeadJudgeID != judgeID {
+		return nil, status.Error(codes.PermissionDenied, "only head judge can resolve")
+	}
+	if err := s.repo.ResolveReportDispute(ctx, d.verdict(reportID, judgeID, true), d.outcome()); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "report not found")
+		}
+		if errors.Is(err, storage.ErrReportNotDisputed) {
+			return nil, status.Error(codes.FailedPrecondition, "report has no dispute")
+		}
+		return nil, status.Errorf(codes.Internal, "update: %v", err)
+	}
+	return s.reloadPBReport(ctx, reportID)
+}
+
+func (s *PolygonServer) reportReviewState(ctx context.Context, rp *storage.Report) (*pb.ReportReviewState, error) {
+	inc, err := s.repo.GetIncident(ctx, rp.IncidentID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get incident: %v", err)
+	}
+	verdicts, err := s.repo.ListReportVerdicts(ctx, rp.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list verdicts: %v", err)
+	}
+	disputedAt, err := s.repo.GetReportDisputedAt(ctx, rp.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get dispute: %v", err)
+	}
+	res := &pb.ReportReviewState{
+		ReportId:     rp.ID.String(),
+		Policy:       pb.ReviewPolicy(inc.Review.Mode),
+		ReviewJudges: uint32(inc.Review.Judges),
+		HeadJudgeId:  optionalUUIDString(inc.Review.HeadJudgeID),
+		Verdicts:     make([]*pb.ReportVerdict, 0, len(verdicts)),
+	}
+	if disputedAt != nil {
+		res.DisputedAt = disputedAt.UTC().Format(time.RFC3339)
+	}
+	for i := range verdicts {
+		res.Verdicts = append(res.Verdicts, toPBReportVerdict(&verdicts[i]))
+	}
+	return res, nil
+}
+
+// parseReviewPolicy проверяет политику проверки инцидента; для SINGLE число судей всегда 1.
+func parseReviewPolicy(mode pb.ReviewPolicy, judges uint32, headJudgeID string) (storage.IncidentReviewPolicy, error) {
+	p := storage.IncidentReviewPolicy{Mode: int32(mode), Judges: int32(judges)}
+	switch p.Mode {
+	case storage.ReviewPolicySingle:
+		p.Judges = 1
+	case storage.ReviewPolicyNApprovals, storage.ReviewPolicyMajority:
+		if p.Judges <= 0 {
+			return p, errors.New("review_judges must be positive")
+		}
+	default:
+		return p, errors.New("invalid review_policy")
+	}
+	if strings.TrimSpace(headJudgeID) != "" {
+		id, err := uuid.Parse(headJudgeID)
+		if err != nil {
+			return p, errors.New("invalid head_judge_id")
+		}
+		p.HeadJudgeID = &id
+	}
+	return p, nil
+}
+
+func toPBReportVerdict(v *storage.ReportVerdict) *pb.ReportVerdict {
+	return &pb.ReportVerdict{
+		JudgeId:        v.JudgeID.String(),
+		Status:         pb.ReportStatus(v.Status),
+		AwardedPercent: v.AwardedPercent,
+		Reason:         v.Reason,
+		Version:        uint32(v.Version),
+		Head:           v.Head,
+		CreatedAt:      v.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Статус апелляции (значения совпадают с pb.AppealStatus).
+const (
+	AppealPending  int32 = 0
+	AppealAccepted int32 = 1
+	AppealDenied   int32 = 2
+)
+
+var (
+	// ErrAppealPending — по отчёту или штрафу уже есть нерассмотренная апелляция.
+	ErrAppealPending = errors.New("appeal already pending")
+	// ErrAppealTargetChanged — отчёт уже не отклонён (или штраф уже отозван), принять апелляцию нельзя.
+	ErrAppealTargetChanged = errors.New("appeal target is no longer contestable")
+)
+
+// Appeal — апелляция команды на итог проверки отчёта (ReportID) или на штраф (FineID).
+type Appeal struct {
+	ID              uuid.UUID
+	TeamID          uuid.UUID
+	EventID         *uuid.UUID
+	ReportID        *uuid.UUID
+	FineID          *uuid.UUID
+	AuthorID        uuid.UUID
+	Justification   string
+	Status          int32
+	DecisionComment string
+	DecidedBy       *uuid.UUID
+	DecidedAt       *time.Time
+	CreatedAt       time.Time
+}
+
+// AppealFilter — фильтры списка апелляций; nil не ограничивает выборку.
+type AppealFilter struct {
+	TeamID  *uuid.UUID
+	EventID *uuid.UUID
+	Status  *int32
+}
+
+func (r *Repo) MigrateAppeals(ctx context.Context) error {
+	stmts := []string{
+		`create table if not exists appeals(
+			id uuid primary key,
+			team_id uuid not null references teams(id) on delete cascade,
+			event_id uuid null references events(id) on delete set null,
+			report_id uuid null references reports(id) on delete cascade,
+			fine_id uuid null references team_fines(id) on delete cascade,
+			author_id uuid not null,
+			justification text not null,
+			status smallint not null default 0,
+			decision_comment text not null default '',
+			decided_by uuid null,
+			decided_at timestamptz null,
+			created_at timestamptz not null default now(),
+			check ((report_id is null) <> (fine_id is null))
+		);`,
+		`create unique index if not exists uq_appeals_pending_report on appeals(report_id) where status=0 and report_id is not null;`,
+		`create unique index if not exists uq_appeals_pending_fine on appeals(fine_id) where status=0 and fine_id is not null;`,
+		`create index if not exists idx_appeals_team on appeals(team_id, created_at);`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const appealColumns = `id, team_id, event_id, report_id, fine_id, author_id, justification, status, decision_comment, decided_by, decided_at, created_at`
+
+func scanAppeal(row pgx.Row, a *Appeal) error {
+	return row.Scan(&a.ID, &a.TeamID, &a.EventID, &a.ReportID, &a.FineID, &a.AuthorID, &a.Justification, &a.Status,
+		&a.DecisionComment, &a.DecidedBy, &a.DecidedAt, &a.CreatedAt)
+}
+
+// InsertAppeal подаёт апелляцию; ErrAppealPending — по этому отчёту или штрафу уже есть нерассмотренная.
+func (r *Repo) InsertAppeal(ctx context.Context, a *Appeal) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	a.Status = AppealPending
+	err := r.pool.QueryRow(ctx, `insert into appeals(id, team_id, event_id, report_id, fine_id, author_id, justification)
+		values ($1,$2,$3,$4,$5,$6,$7) returning created_at`, a.ID, a.TeamID, a.EventID, a.ReportID, a.FineID, a.AuthorID, a.Justification).Scan(&a.CreatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			return ErrAppealPending
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *Repo) GetAppeal(ctx context.Context, id uuid.UUID) (*Appeal, error) {
+	var a Appeal
+	if err := scanAppeal(r.pool.QueryRow(ctx, `select `+appealColumns+` from appeals where id=$1`, id), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAppeals — апелляции под фильтром, старые первыми.
+func (r *Repo) ListAppeals(ctx context.Context, f AppealFilter) ([]Appeal, error) {
+	rows, err := r.pool.Query(ctx, `select `+appealColumns+` from appeals
+		where ($1::uuid is null or team_id=$1) and ($2::uuid is null or event_id=$2) and ($3::smallint is null or status=$3)
+		order by created_at`, f.TeamID, f.EventID, f.Status)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Appeal, error) {
+		var a Appeal
+		err := scanAppeal(row, &a)
+		return a, err
+	})
+}
+
+// DecideAppeal записывает решение по нерассмотренной апелляции. При принятии в той же транзакции
+// отклонённый (или частично принятый) отчёт возвращается в PENDING новой версией, а штраф отзывается.
+// pgx.ErrNoRows — апелляции нет или она уже рассмотрена; ErrAppealTargetChanged — принять нельзя.
+func (r *Repo) DecideAppeal(ctx context.Context, id uuid.UUID, status int32, decidedBy uuid.UUID, comment string) (*Appeal, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var a Appeal
+	if err := scanAppeal(tx.QueryRow(ctx, `update appeals set status=$2, decided_by=$3, decided_at=now(), decision_comment=$4
+		where id=$1 and status=0 returning `+appealColumns, id, status, decidedBy, comment), &a); err != nil {
+		return nil, err
+	}
+	if status == AppealAccepted {
+		switch {
+		case a.ReportID != nil:
+			err = reopenReport(ctx, tx, *a.ReportID)
+		case a.FineID != nil:
+			err = revokeTeamFine(ctx, tx, *a.FineID)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAppealTargetChanged
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// reopenReport возвращает отклонённый или частично принятый отчёт на проверку новой версией (чтобы
+// прежние вердикты не учитывались) и пересчитывает начисления по инциденту;
+// pgx.ErrNoRows — отчёт в другом статусе.
+func reopenReport(ctx context.Context, tx pgx.Tx, reportID uuid.UUID) error {
+	var incidentID uuid.UUID
+	if err := tx.QueryRow(ctx, `update reports set status=1, awarded_percent=null, rejection_reason=null, review_disputed_at=null, updated_at=now()
+		where id=$1 and status in (3,4) returning incident_id`, reportID).Scan(&incidentID); err != nil {
+		return err
+	}
+	if err := snapshotReportVersion(ctx, tx, reportID); err != nil {
+		return err
+	}
+	return settleIncidentLedger(ctx, tx, incidentID, false)
+}
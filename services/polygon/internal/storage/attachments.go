@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Attachment — вложение отчёта. StepNumber — номер шага отчёта (шаги пересоздаются при
+// редактировании, поэтому привязка идёт по номеру, а не по id шага); nil — вложение ко всему отчёту.
+type Attachment struct {
+	ID          uuid.UUID
+	ReportID    uuid.UUID
+	StepNumber  *int32
+	URL         string
+	ObjectKey   string
+	ContentType string
+	Size        int64
+	CreatedAt   time.Time
+}
+
+func (r *Repo) MigrateAttachments(ctx context.Context) error {
+	stmts := []string{
+		`alter table report_attachments add column if not exists step_number int null;`,
+		`create index if not exists idx_report_attachments_report on report_attachments(report_id);`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const attachmentColumns = `id, report_id, step_number, url, object_key, content_type, size, created_at`
+
+func scanAttachment(row pgx.Row, a *Attachment) error {
+	return row.Scan(&a.ID, &a.ReportID, &a.StepNumber, &a.URL, &a.ObjectKey, &a.ContentType, &a.Size, &a.CreatedAt)
+}
+
+func (r *Repo) ListReportAttachments(ctx context.Context, reportID uuid.UUID) ([]Attachment, error) {
+	rows, err := r.pool.Query(ctx, `select `+attachmentColumns+` from report_attachments where report_id=$1 order by created_at`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Attachment, error) {
+		var a Attachment
+		err := scanAttachment(row, &a)
+		return a, err
+	})
+}
+
+func (r *Repo) InsertAttachment(ctx context.Context, a *Attachment) error {
+	return r.pool.QueryRow(ctx, `insert into report_attachments(id,report_id,step_number,url,object_key,content_type,size) values ($1,$2,$3,$4,$5,$6,$7) returning created_at`,
+		a.ID, a.ReportID, a.StepNumber, a.URL, a.ObjectKey, a.ContentType, a.Size).Scan(&a.CreatedAt)
+}
+
+func (r *Repo) GetAttachment(ctx context.Context, id uuid.UUID) (*Attachment, error) {
+	var a Attachment
+	if err := scanAttachment(r.pool.QueryRow(ctx, `select `+attachmentColumns+` from report_attachments where id=$1`, id), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *Repo) DeleteAttachment(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `delete from report_attachments where id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// detachRemovedStepAttachments переносит на уровень отчёта вложения шагов, которых после
+// редактирования больше нет (номер больше stepCount).
+func detachRemovedStepAttachments(ctx context.Context, tx pgx.Tx, reportID uuid.UUID, stepCount int32) error {
+	_, err := tx.Exec(ctx, `update report_attachments set step_number=null where report_id=$1 and step_number>$2`, reportID, stepCount)
+	return err
+}
+
+// FilterAttachmentKeys — подмножество keys, на которые ссылаются записи report_attachments.
+func (r *Repo) FilterAttachmentKeys(ctx context.Context, keys []string) (map[string]bool, error) {
+	rows, err := r.pool.Query(ctx, `select object_key from report_attachments where object_key = any($1)`, keys)
+	if err != nil {
+		return nil, err
+	}
+	list, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool, len(list))
+	for _, k := range list {
+		res[k] = true
+	}
+	return res, nil
+}
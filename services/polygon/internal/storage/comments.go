@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReportComment — сообщение в обсуждении отчёта. AuthorTeamID=nil — комментарий судьи;
+// StepNumber=nil — комментарий ко всему отчёту.
+type ReportComment struct {
+	ID           uuid.UUID
+	ReportID     uuid.UUID
+	StepNumber   *int32
+	AuthorID     uuid.UUID
+	AuthorTeamID *uuid.UUID
+	Body         string
+	CreatedAt    time.Time
+}
+
+func (r *Repo) MigrateComments(ctx context.Context) error {
+	stmts := []string{
+		`create table if not exists report_comments(
+			id uuid primary key,
+			report_id uuid not null references reports(id) on delete cascade,
+			step_number int null,
+			author_id uuid not null,
+			author_team_id uuid null,
+			body text not null,
+			created_at timestamptz not null default now()
+		);`,
+		`create index if not exists idx_report_comments_report on report_comments(report_id, created_at);`,
+		`create table if not exists report_comment_reads(
+			report_id uuid not null references reports(id) on delete cascade,
+			team_id uuid not null,
+			read_at timestamptz not null,
+			primary key(report_id, team_id)
+		);`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repo) InsertReportComment(ctx context.Context, c *ReportComment) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return r.pool.QueryRow(ctx, `insert into report_comments(id, report_id, step_number, author_id, author_team_id, body)
+		values ($1,$2,$3,$4,$5,$6) returning created_at`, c.ID, c.ReportID, c.StepNumber, c.AuthorID, c.AuthorTeamID, c.Body).Scan(&c.CreatedAt)
+}
+
+// ListReportComments — обсуждение отчёта в порядке написания.
+func (r *Repo) ListReportComments(ctx context.Context, reportID uuid.UUID) ([]ReportComment, error) {
+	rows, err := r.pool.Query(ctx, `select id, report_id, step_number, author_id, author_team_id, body, created_at
+		from report_comments where report_id=$1 order by created_at, id`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ReportComment, error) {
+		var c ReportComment
+		err := row.Scan(&c.ID, &c.ReportID, &c.StepNumber, &c.AuthorID, &c.AuthorTeamID, &c.Body, &c.CreatedAt)
+		return c, err
+	})
+}
+
+// MarkReportCommentsRead отмечает прочитанными командой комментарии к отчёту, созданные не позже upTo
+// (последнего показанного ей комментария). Отметка только сдвигается вперёд, поэтому комментарии,
+// появившиеся после выборки, остаются непрочитанными.
+func (r *Repo) MarkReportCommentsRead(ctx context.Context, reportID, teamID uuid.UUID, upTo time.Time) error {
+	_, err := r.pool.Exec(ctx, `insert into report_comment_reads(report_id, team_id, read_at) values ($1,$2,$3)
+		on conflict (report_id, team_id) do update set read_at=greatest(report_comment_reads.read_at, excluded.read_at)`, reportID, teamID, upTo)
+	return err
+}
+
+// CountUnreadComments — число непрочитанных командой комментариев судей по каждому из отчётов.
+// Отчёты без непрочитанных в результат не попадают.
+func (r *Repo) CountUnreadComments(ctx context.Context, teamID uuid.UUID, reportIDs []uuid.UUID) (map[uuid.UUID]int32, error) {
+	res := map[uuid.UUID]int32{}
+	if len(reportIDs) == 0 {
+		return res, nil
+	}
+	rows, err := r.pool.Query(ctx, `select c.report_id, count(*)
+		from report_comments c
+		left join report_comment_reads rd on rd.report_id=c.report_id and rd.team_id=$1
+		where c.report_id = any($2) and c.author_team_id is null and (rd.read_at is null or c.created_at > rd.read_at)
+		group by c.report_id`, teamID, reportIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id uuid.UUID
+			n  int64
+		)
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		res[id] = int32(n)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Event — соревнование: расписание, участвующие команды и полигоны. Отчёты, штрафы и записи
+// журнала очков привязываются к соревнованию, поэтому архивные соревнования остаются доступны
+// для чтения, а новое можно провести без очистки базы.
+type Event struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	StartsAt    time.Time
+	EndsAt      time.Time
+	ArchivedAt  *time.Time
+	CreatedAt   time.Time
+	TeamIDs     []uuid.UUID
+	PolygonIDs  []uuid.UUID
+}
+
+// Running — идёт ли соревнование в момент now.
+func (e *Event) Running(now time.Time) bool {
+	return e.ArchivedAt == nil && !now.Before(e.StartsAt) && now.Before(e.EndsAt)
+}
+
+// HasPolygon — входит ли полигон в соревнование.
+func (e *Event) HasPolygon(id uuid.UUID) bool {
+	for _, pid := range e.PolygonIDs {
+		if pid == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrEventInUse — у соревнования уже есть отчёты, штрафы или записи журнала очков.
+var ErrEventInUse = errors.New("event has reports or score entries")
+
+// ScoreScope ограничивает подсчёт очков: EventID — только начисления соревнования (nil — все),
+// Until — только записи до момента заморозки скорборда (nil — все).
+type ScoreScope struct {
+	EventID *uuid.UUID
+	Until   *time.Time
+}
+
+func (r *Repo) MigrateEvents(ctx context.Context) error {
+	stmts := []string{
+		`create table if not exists events(
+			id uuid primary key,
+			name text not null,
+			description text not null default '',
+			starts_at timestamptz not null,
+			ends_at timestamptz not null,
+			archived_at timestamptz null,
+			created_at timestamptz not null default now(),
+			updated_at timestamptz not null default now()
+		);`,
+		`create table if not exists event_teams(
+			event_id uuid not null references events(id) on delete cascade,
+			team_id uuid not null references teams(id) on delete cascade,
+			primary key(event_id, team_id)
+		);`,
+		`create index if not exists idx_event_teams_team on event_teams(team_id);`,
+		`create table if not exists event_polygons(
+			event_id uuid not null references events(id) on delete cascade,
+			polygon_id uuid not null references polygons(id) on delete cascade,
+			primary key(event_id, polygon_id)
+		);`,
+		// event_id = null — данные, созданные вне соревнований (до их появления).
+		`alter table reports add column if not exists event_id uuid null references events(id);`,
+		`create index if not exists idx_reports_event on reports(event_id);`,
+		`alter table team_fines add column if not exists event_id uuid null references events(id);`,
+		`alter table initial_items add column if not exists event_id uuid null references events(id) on delete cascade;`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const eventColumns = `id, name, description, starts_at, ends_at, archived_at, created_at`
+
+func scanEvent(row pgx.Row, e *Event) error {
+	return row.Scan(&e.ID, &e.Name, &e.Description, &e.StartsAt, &e.EndsAt, &e.ArchivedAt, &e.CreatedAt)
+}
+
+func (r *Repo) CreateEvent(ctx context.Context, e *Event) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := tx.QueryRow(ctx, `insert into events(id, name, description, starts_at, ends_at) values ($1,$2,$3,$4,$5) returning created_at`,
+		e.ID, e.Name, e.Description, e.StartsAt, e.EndsAt).Scan(&e.CreatedAt); err != nil {
+		return err
+	}
+	for _, tid := range e.TeamIDs {
+		if _, err := tx.Exec(ctx, `insert into event_teams(event_id, team_id) values ($1,$2) on conflict do nothing`, e.ID, tid); err != nil {
+			return err
+		}
+	}
+	for _, pid := range e.PolygonIDs {
+		if _, err := tx.Exec(ctx, `insert into event_polygons(event_id, polygon_id) values ($1,$2) on conflict do nothing`, e.ID, pid); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// UpdateEvent — частичное обновление; archived=true переводит соревнование в архив, false — возвращает.
+func (r *Repo) UpdateEvent(ctx context.Context, id uuid.UUID, name, description *string, startsAt, endsAt *time.Time, archived *bool) error {
+	sets := []string{}
+	args := []any{}
+	idx := 1
+	if name != nil {
+		sets = append(sets, "name=$"+strconv.Itoa(idx))
+		args = append(args, *name)
+		idx++
+	}
+	if description != nil {
+		sets = append(sets, "description=$"+strconv.Itoa(idx))
+		args = append(args, *description)
+		idx++
+	}
+	if startsAt != nil {
+		sets = append(sets, "starts_at=$"+strconv.Itoa(idx))
+		args = append(args, *startsAt)
+		idx++
+	}
+	if endsAt != nil {
+		sets = append(sets, "ends_at=$"+strconv.Itoa(idx))
+		args = append(args, *endsAt)
+		idx++
+	}
+	if archived != nil {
+		if *archived {
+			sets = append(sets, "archived_at=coalesce(archived_at, now())")
+		} else {
+			sets = append(sets, "archived_at=null")
+		}
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	sets = append(sets, "updated_at=now()")
+	args = append(args, id)
+	ct, err := r.pool.Exec(ctx, "update events set "+strings.Join(sets, ",")+" where id=$"+strconv.Itoa(idx), args...)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteEvent удаляет соревнование, к которому ещё ничего не привязано; иначе — ErrEventInUse
+// (такое соревнование следует архивировать).
+func (r *Repo) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	var used bool
+	if err := r.pool.QueryRow(ctx, `select exists(select 1 from reports where event_id=$1)
+		or exists(select 1 from team_fines where event_id=$1)
+		or exists(select 1 from score_ledger where event_id=$1)`, id).Scan(&used); err != nil {
+		return err
+	}
+	if used {
+		return ErrEventInUse
+	}
+	ct, err := r.pool.Exec(ctx, `delete from events where id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repo) GetEvent(ctx context.Context, id uuid.UUID) (*Event, error) {
+	var e Event
+	if err := scanEvent(r.pool.QueryRow(ctx, `select `+eventColumns+` from events where id=$1`, id), &e); err != nil {
+		return nil, err
+	}
+	if err := r.loadEventMembers(ctx, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ListEvents — соревнования по убыванию начала; teamID != nil — только с участием команды.
+func (r *Repo) ListEvents(ctx context.Context, teamID *uuid.UUID) ([]Event, error) {
+	rows, err := r.pool.Query(ctx, `select `+eventColumns+` from events
+		where $1::uuid is null or id in (select event_id from event_teams where team_id=$1)
+		order by starts_at desc`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+		var e Event
+		err := scanEvent(row, &e)
+		return e, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if err := r.loadEventMembers(ctx, &list[i]); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// GetTeamCurrentEvent — текущее соревнование команды среди неархивных: идущее, иначе ближайшее
+// по времени начала. pgx.ErrNoRows — команда не участвует ни в одном.
+func (r *Repo) GetTeamCurrentEvent(ctx context.Context, teamID uuid.UUID) (*Event, error) {
+	var e Event
+	err := scanEvent(r.pool.QueryRow(ctx, `select `+eventColumns+` from events
+		where archived_at is null and id in (select event_id from event_teams where team_id=$1)
+		order by case when now() >= starts_at and now() < ends_at then 0 else 1 end,
+			abs(extract(epoch from starts_at - now()))
+		limit 1`, teamID), &e)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadEventMembers(ctx, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// TeamHasEvents — включалась ли команда хотя бы в одно соревнование (в том числе архивное).
+func (r *Repo) TeamHasEvents(ctx context.Context, teamID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.pool.QueryRow(ctx, `select exists(select 1 from event_teams where team_id=$1)`, teamID).Scan(&ok)
+	return ok, err
+}
+
+func (r *Repo) loadEventMembers(ctx context.Context, e *Event) error {
+	rows, err := r.pool.Query(ctx, `select team_id from event_teams where event_id=$1`, e.ID)
+	if err != nil {
+		return err
+	}
+	if e.TeamIDs, err = pgx.CollectRows(rows, pgx.RowTo[uuid.UUID]); err != nil {
+		return err
+	}
+	rows, err = r.pool.Query(ctx, `select polygon_id from event_polygons where event_id=$1`, e.ID)
+	if err != nil {
+		return err
+	}
+	e.PolygonIDs, err = pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	return err
+}
+
+// AddEventTeam добавляет команду в соревнование и пересчитывает его начисления
+// (списания у синих команд зависят от состава участников).
+func (r *Repo) AddEventTeam(ctx context.Context, eventID, teamID uuid.UUID) error {
+	return r.changeEventTeams(ctx, eventID, `insert into event_teams(event_id, team_id) values ($1,$2) on conflict do nothing`, teamID)
+}
+
+func (r *Repo) RemoveEventTeam(ctx context.Context, eventID, teamID uuid.UUID) error {
+	return r.changeEventTeams(ctx, eventID, `delete from event_teams where event_id=$1 and team_id=$2`, teamID)
+}
+
+func (r *Repo) changeEventTeams(ctx context.Context, eventID uuid.UUID, q string, teamID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, q, eventID, teamID); err != nil {
+		return err
+	}
+	if err := settleEventLedger(ctx, tx, eventID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repo) AddEventPolygon(ctx context.Context, eventID, polygonID uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `insert into event_polygons(event_id, polygon_id) values ($1,$2) on conflict do nothing`, eventID, polygonID)
+	return err
+}
+
+func (r *Repo) RemoveEventPolygon(ctx context.Context, eventID, polygonID uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `delete from event_polygons where event_id=$1 and polygon_id=$2`, eventID, polygonID)
+	return err
+}
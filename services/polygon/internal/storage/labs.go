@@ -21,6 +21,46 @@ type Lab struct {
 	GroupID     *uuid.UUID
 	StepCount   int32
 	CreatedAt   time.Time
+
+	Provisioning   LabProvisioning
+	ProvisionJobID string
+	TeardownJobID  string
+	Sequential     bool
+	PausedAt       *time.Time
+	PausedSeconds  int64
+}
+
+// Значения совпадают с labv1.LabProvisionType.
+const (
+	LabProvisionNone      int32 = 0
+	LabProvisionTerraform int32 = 1
+	LabProvisionAnsible   int32 = 2
+	LabProvisionJenkins   int32 = 3
+)
+
+// LabProvisioning — рецепт развёртывания инфраструктуры лабы, хранится в labs.provisioning (jsonb).
+type LabProvisioning struct {
+	Type                        int32           `json:"type"`
+	TerraformWorkspace          string          `json:"terraform_workspace,omitempty"`
+	SemaphoreProjectID          int32           `json:"semaphore_project_id,omitempty"`
+	SemaphoreTemplateID         int32           `json:"semaphore_template_id,omitempty"`
+	SemaphoreTeardownTemplateID int32           `json:"semaphore_teardown_template_id,omitempty"`
+	JenkinsJob                  string          `json:"jenkins_job,omitempty"`
+	JenkinsTeardownJob          string          `json:"jenkins_teardown_job,omitempty"`
+	Params                      json.RawMessage `json:"params,omitempty"`
+}
+
+// HasTeardown — есть ли у рецепта шаг удаления инфраструктуры.
+func (p *LabProvisioning) HasTeardown() bool {
+	switch p.Type {
+	case LabProvisionTerraform:
+		return p.TerraformWorkspace != ""
+	case LabProvisionAnsible:
+		return p.SemaphoreTeardownTemplateID != 0
+	case LabProvisionJenkins:
+		return p.JenkinsTeardownJob != ""
+	}
+	return false
 }
 
 type LabStep struct {
@@ -60,6 +100,29 @@ func (r *Repo) MigrateLabs(ctx context.Context) error {
 			order_index int not null default 0
 		);`,
 		`create index if not exists idx_lab_steps_lab on lab_steps(lab_id);`,
+		`alter table labs add column if not exists provisioning jsonb not null default '{}'`,
+		`alter table labs add column if not exists provision_job_id text not null default ''`,
+		`alter table labs add column if not exists teardown_job_id text not null default ''`,
+		`create table if not exists lab_step_attempts(
+			id uuid primary key,
+			lab_id uuid not null references labs(id) on delete cascade,
+			step_id uuid not null references lab_steps(id) on delete cascade,
+			user_id uuid not null,
+			team_id uuid,
+			answer text not null,
+			correct boolean not null,
+			created_at timestamptz not null default now()
+		);`,
+		`create index if not exists idx_lab_step_attempts_step on lab_step_attempts(step_id);`,
+		`create index if not exists idx_lab_step_attempts_team on lab_step_attempts(team_id);`,
+		`create index if not exists idx_lab_step_attempts_user on lab_step_attempts(user_id);`,
+		`alter table labs add column if not exists sequential boolean not null default false`,
+		`alter table labs add column if not exists paused_at timestamptz`,
+		`alter table labs add column if not exists paused_seconds bigint not null default 0`,
+		`alter table labs add column if not exists provision_attempts int not null default 0`,
+		`alter table labs add column if not exists provision_retry_at timestamptz`,
+		`alter table labs add column if not exists teardown_attempts int not null default 0`,
+		`alter table labs add column if not exists teardown_retry_at timestamptz`,
 	}
 	for _, s := range stmts {
 		if _, err := r.pool.Exec(ctx, s); err != nil {
@@ -70,21 +133,23 @@ func (r *Repo) MigrateLabs(ctx context.Context) error {
 }
 
 func (r *Repo) CreateLab(ctx context.Context, lab *Lab) error {
-	_, err := r.pool.Exec(ctx, `insert into labs(id, polygon_id, title, description, started_at, ttl_seconds, group_id, step_count, created_at)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		lab.ID, lab.PolygonID, lab.Title, lab.Description, lab.StartedAt, lab.TTLSeconds, lab.GroupID, lab.StepCount, lab.CreatedAt)
+	prov, err := json.Marshal(lab.Provisioning)
+	if err != nil {
+		return err
+	}
+	_, err = r.pool.Exec(ctx, `insert into labs(id, polygon_id, title, description, started_at, ttl_seconds, group_id, step_count, created_at, provisioning, sequential)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		lab.ID, lab.PolygonID, lab.Title, lab.Description, lab.StartedAt, lab.TTLSeconds, lab.GroupID, lab.StepCount, lab.CreatedAt, prov, lab.Sequential)
 	return err
 }
 
 func (r *Repo) GetLab(ctx context.Context, id uuid.UUID) (*Lab, error) {
-	row := r.pool.QueryRow(ctx, `select id, polygon_id, title, description, started_at, ttl_seconds, group_id, step_count, created_at
-		from labs where id = $1`, id)
+	row := r.pool.QueryRow(ctx, `select `+labColumns+` from labs where id = $1`, id)
 	return scanLab(row)
 }
 
 func (r *Repo) GetLabByPolygon(ctx context.Context, polygonID uuid.UUID) (*Lab, error) {
-	row := r.pool.QueryRow(ctx, `select id, polygon_id, title, description, started_at, ttl_seconds, group_id, step_count, created_at
-		from labs where polygon_id = $1 order by created_at desc limit 1`, polygonID)
+	row := r.pool.QueryRow(ctx, `select `+labColumns+` from labs where polygon_id = $1 order by created_at desc limit 1`, polygonID)
 	return scanLab(row)
 }
 
@@ -92,29 +157,151 @@ func (r *Repo) ListLabs(ctx context.Context, polygonID *uuid.UUID) ([]Lab, error
 	var rows pgx.Rows
 	var err error
 	if polygonID != nil {
-		rows, err = r.pool.Query(ctx, `select id, polygon_id, title, description, started_at, ttl_seconds, group_id, step_count, created_at
-			from labs where polygon_id = $1 order by created_at desc`, *polygonID)
+		rows, err = r.pool.Query(ctx, `select `+labColumns+` from labs where polygon_id = $1 order by created_at desc`, *polygonID)
 	} else {
-		rows, err = r.pool.Query(ctx, `select id, polygon_id, title, description, started_at, ttl_seconds, group_id, step_count, created_at
-			from labs order by created_at desc`)
+		rows, err = r.pool.Query(ctx, `select `+labColumns+` from labs order by created_at desc`)
 	}
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var labs []Lab
-	for rows.Next() {
-		var lab Lab
-		if err := rows.Scan(&lab.ID, &lab.PolygonID, &lab.Title, &lab.Description, &lab.StartedAt, &lab.TTLSeconds, &lab.GroupID, &lab.StepCount, &lab.CreatedAt); err != nil {
-			return nil, err
-		}
-		labs = append(labs, lab)
+	return collectLabs(rows)
+}
+
+// LabProvisionMaxAttempts — после стольких неудачных запусков provision-рана (teardown-рана) лаба больше
+// не развёртывается (не удаляется) автоматически, до перезапуска лабы.
+const LabProvisionMaxAttempts = 10
+
+// ListLabsToProvision — лабы с рецептом, время старта которых наступило, а provision-ран ещё не запускался.
+// Лабы с неудачными попытками возвращаются не раньше provision_retry_at и не более LabProvisionMaxAttempts раз.
+func (r *Repo) ListLabsToProvision(ctx context.Context, now time.Time) ([]Lab, error) {
+	rows, err := r.pool.Query(ctx, `select `+labColumns+` from labs
+		where (provisioning->>'type')::int > 0 and started_at is not null and started_at <= $1 and provision_job_id = ''
+			and provision_attempts < $2 and (provision_retry_at is null or provision_retry_at <= $1)
+		order by started_at`, now, LabProvisionMaxAttempts)
+	if err != nil {
+		return nil, err
 	}
-	return labs, rows.Err()
+	defer rows.Close()
+	return collectLabs(rows)
+}
+
+// ListLabsToTeardown — развёрнутые лабы с истёкшим TTL и шагом удаления в рецепте (HasTeardown),
+// для которых teardown-ран ещё не запускался. Лабы с неудачными попытками возвращаются не раньше
+// teardown_retry_at и не более LabProvisionMaxAttempts раз.
+func (r *Repo) ListLabsToTeardown(ctx context.Context, now time.Time) ([]Lab, error) {
+	rows, err := r.pool.Query(ctx, `select `+labColumns+` from labs
+		where provision_job_id <> '' and teardown_job_id = '' and ttl_seconds > 0 and paused_at is null
+			and started_at + make_interval(secs => ttl_seconds + paused_seconds) <= $1
+			and case (provisioning->>'type')::int
+				when 1 then coalesce(provisioning->>'terraform_workspace', '') <> ''
+				when 2 then coalesce((provisioning->>'semaphore_teardown_template_id')::int, 0) <> 0
+				when 3 then coalesce(provisioning->>'jenkins_teardown_job', '') <> ''
+				else false end
+			and teardown_attempts < $2 and (teardown_retry_at is null or teardown_retry_at <= $1)
+		order by started_at`, now, LabProvisionMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectLabs(rows)
+}
+
+func (r *Repo) SetLabProvisionJob(ctx context.Context, id uuid.UUID, jobID string) error {
+	ct, err := r.pool.Exec(ctx, `update labs set provision_job_id=$2, provision_attempts=0, provision_retry_at=null where id=$1`, id, jobID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
 }
 
-func (r *Repo) UpdateLab(ctx context.Context, id uuid.UUID, title, description *string, startedAt *time.Time, ttlSeconds *int64, groupID *uuid.UUID) error {
+func (r *Repo) SetLabTeardownJob(ctx context.Context, id uuid.UUID, jobID string) error {
+	ct, err := r.pool.Exec(ctx, `update labs set teardown_job_id=$2, teardown_attempts=0, teardown_retry_at=null where id=$1`, id, jobID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// FailLabProvision учитывает неудачный запуск provision-рана и откладывает следующую попытку
+// с экспоненциальной задержкой от base (не больше часа). Возвращает число сделанных попыток.
+func (r *Repo) FailLabProvision(ctx context.Context, id uuid.UUID, base time.Duration) (int32, error) {
+	var attempts int32
+	err := r.pool.QueryRow(ctx, `update labs set provision_attempts=provision_attempts+1,
+			provision_retry_at=now() + make_interval(secs => least(3600, $2 * power(2, provision_attempts)))
+		where id=$1 returning provision_attempts`, id, base.Seconds()).Scan(&attempts)
+	return attempts, err
+}
+
+// FailLabTeardown — как FailLabProvision, для запуска teardown-рана.
+func (r *Repo) FailLabTeardown(ctx context.Context, id uuid.UUID, base time.Duration) (int32, error) {
+	var attempts int32
+	err := r.pool.QueryRow(ctx, `update labs set teardown_attempts=teardown_attempts+1,
+			teardown_retry_at=now() + make_interval(secs => least(3600, $2 * power(2, teardown_attempts)))
+		where id=$1 returning teardown_attempts`, id, base.Seconds()).Scan(&attempts)
+	return attempts, err
+}
+
+// StartLab выставляет время старта и сбрасывает паузы. Job развёртывания и удаления, а также
+// неудачные попытки сбрасываются, чтобы перезапущенная лаба была развёрнута заново.
+func (r *Repo) StartLab(ctx context.Context, id uuid.UUID, at time.Time) error {
+	ct, err := r.pool.Exec(ctx, `update labs set started_at=$2, paused_at=null, paused_seconds=0,
+		provision_job_id='', teardown_job_id='', provision_attempts=0, provision_retry_at=null,
+		teardown_attempts=0, teardown_retry_at=null where id=$1`, id, at)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repo) PauseLab(ctx context.Context, id uuid.UUID, at time.Time) error {
+	ct, err := r.pool.Exec(ctx, `update labs set paused_at=$2 where id=$1 and paused_at is null`, id, at)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ResumeLab снимает паузу, добавляя её длительность к paused_seconds.
+func (r *Repo) ResumeLab(ctx context.Context, id uuid.UUID, at time.Time) error {
+	ct, err := r.pool.Exec(ctx, `update labs set paused_seconds = paused_seconds + greatest(0, extract(epoch from ($2::timestamptz - paused_at)))::bigint, paused_at=null
+		where id=$1 and paused_at is not null`, id, at)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ExtendLab продлевает TTL лабы, инфраструктура которой ещё не удалялась; pgx.ErrNoRows — лабы нет
+// или teardown-ран уже запущен.
+func (r *Repo) ExtendLab(ctx context.Context, id uuid.UUID, seconds int64) error {
+	ct, err := r.pool.Exec(ctx, `update labs set ttl_seconds = ttl_seconds + $2 where id=$1 and teardown_job_id = ''`, id, seconds)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repo) UpdateLab(ctx context.Context, id uuid.UUID, title, description *string, startedAt *time.Time, ttlSeconds *int64, groupID *uuid.UUID, provisioning *LabProvisioning, sequential *bool) error {
 	sets := []string{}
 	args := []any{}
 	idx := 1
@@ -144,6 +331,20 @@ func (r *Repo) UpdateLab(ctx context.Context, id uuid.UUID, title, description *
 		args = append(args, *groupID)
 		idx++
 	}
+	if provisioning != nil {
+		prov, err := json.Marshal(provisioning)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, "provisioning=$"+strconv.Itoa(idx))
+		args = append(args, prov)
+		idx++
+	}
+	if sequential != nil {
+		sets = append(sets, "sequential=$"+strconv.Itoa(idx))
+		args = append(args, *sequential)
+		idx++
+	}
 
 	if len(sets) == 0 {
 		return nil
@@ -301,14 +502,105 @@ func (r *Repo) DeleteLabStep(ctx context.Context, id uuid.UUID) error {
 	return r.UpdateLabStepCount(ctx, labID)
 }
 
+type LabStepAttempt struct {
+	ID        uuid.UUID
+	LabID     uuid.UUID
+	StepID    uuid.UUID
+	UserID    uuid.UUID
+	TeamID    *uuid.UUID
+	Answer    string
+	Correct   bool
+	CreatedAt time.Time
+}
+
+func (r *Repo) CreateLabStepAttempt(ctx context.Context, a *LabStepAttempt) error {
+	_, err := r.pool.Exec(ctx, `insert into lab_step_attempts(id, lab_id, step_id, user_id, team_id, answer, correct, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		a.ID, a.LabID, a.StepID, a.UserID, a.TeamID, a.Answer, a.Correct, a.CreatedAt)
+	return err
+}
+
+// CountLabStepAttempts — число попыток по шагу: командных, если teamID задан, иначе личных.
+func (r *Repo) CountLabStepAttempts(ctx context.Context, stepID, userID uuid.UUID, teamID *uuid.UUID) (int32, error) {
+	var n int32
+	var err error
+	if teamID != nil {
+		err = r.pool.QueryRow(ctx, `select count(*) from lab_step_attempts where step_id = $1 and team_id = $2`, stepID, *teamID).Scan(&n)
+	} else {
+		err = r.pool.QueryRow(ctx, `select count(*) from lab_step_attempts where step_id = $1 and user_id = $2`, stepID, userID).Scan(&n)
+	}
+	return n, err
+}
+
+// LabStepProgress — агрегат попыток по шагу для одного владельца (команды или пользователя).
+type LabStepProgress struct {
+	OwnerID  uuid.UUID
+	StepID   uuid.UUID
+	Attempts int32
+	SolvedAt *time.Time
+	SolvedBy *uuid.UUID
+}
+
+// ListLabStepProgress агрегирует попытки по шагам лабы. byUser=false — группировка по командам
+// (попытки пользователей вне команд не учитываются), byUser=true — по пользователям.
+// owner, если задан, ограничивает выборку одной командой / пользователем.
+func (r *Repo) ListLabStepProgress(ctx context.Context, labID uuid.UUID, byUser bool, owner *uuid.UUID) ([]LabStepProgress, error) {
+	col := "team_id"
+	if byUser {
+		col = "user_id"
+	}
+	q := `select ` + col + `, step_id, count(*)::int,
+			min(created_at) filter (where correct),
+			(array_agg(user_id order by created_at) filter (where correct))[1]
+		from lab_step_attempts
+		where lab_id = $1 and ` + col + ` is not null and ($2::uuid is null or ` + col + ` = $2)
+		group by ` + col + `, step_id
+		order by ` + col
+	rows, err := r.pool.Query(ctx, q, labID, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []LabStepProgress
+	for rows.Next() {
+		var p LabStepProgress
+		if err := rows.Scan(&p.OwnerID, &p.StepID, &p.Attempts, &p.SolvedAt, &p.SolvedBy); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+const labColumns = `id, polygon_id, title, description, started_at, ttl_seconds, group_id, step_count, created_at, provisioning, provision_job_id, teardown_job_id, sequential, paused_at, paused_seconds`
+
 func scanLab(row pgx.Row) (*Lab, error) {
 	var lab Lab
-	if err := row.Scan(&lab.ID, &lab.PolygonID, &lab.Title, &lab.Description, &lab.StartedAt, &lab.TTLSeconds, &lab.GroupID, &lab.StepCount, &lab.CreatedAt); err != nil {
+	var prov []byte
+	if err := row.Scan(&lab.ID, &lab.PolygonID, &lab.Title, &lab.Description, &lab.StartedAt, &lab.TTLSeconds, &lab.GroupID, &lab.StepCount, &lab.CreatedAt, &prov, &lab.ProvisionJobID, &lab.TeardownJobID, &lab.Sequential, &lab.PausedAt, &lab.PausedSeconds); err != nil {
 		return nil, err
 	}
+	if len(prov) > 0 {
+		if err := json.Unmarshal(prov, &lab.Provisioning); err != nil {
+			return nil, err
+		}
+	}
 	return &lab, nil
 }
 
+func collectLabs(rows pgx.Rows) ([]Lab, error) {
+	var labs []Lab
+	for rows.Next() {
+		lab, err := scanLab(rows)
+		if err != nil {
+			return nil, err
+		}
+		labs = append(labs, *lab)
+	}
+	return labs, rows.Err()
+}
+
 func scanLabStep(row pgx.Row) (*LabStep, error) {
 	var step LabStep
 	if err := row.Scan(&step.ID, &step.LabID, &step.Title, &step.Description, &step.InitialItems, &step.HasAnswer, &step.Answer, &step.OrderIndex); err != nil {
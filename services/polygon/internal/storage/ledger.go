@@ -0,0 +1,547 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Виды записей журнала очков; значения совпадают с pb.ScoreLedgerKind.
+const (
+	LedgerRedAward         int32 = 1 // награда красной команде за первую принятую реализацию инцидента
+	LedgerBlueShare        int32 = 2 // доля синих: + синей команде за отражение, − красной команде из её награды
+	LedgerBlueLoss         int32 = 3 // списание базового приза у синей команды полигона
+	LedgerFine             int32 = 4
+	LedgerFineRevocation   int32 = 5
+	LedgerManualAdjustment int32 = 6
+	LedgerBonus            int32 = 7 // бонус за первые принятия (first/second/third blood) и самую быструю защиту
+)
+
+// Режимы начисления очков красным за инцидент; значения совпадают с pb.IncidentScoringMode.
+const (
+	IncidentScoringFirstOnly int32 = 0 // base_prize только первой принятой команде
+	IncidentScoringFixed     int32 = 1 // base_prize каждой команде с принятым отчётом
+	IncidentScoringDynamic   int32 = 2 // стоимость убывает с числом решивших, пересчитывается всем
+)
+
+// IncidentScoring — режим начисления очков по инциденту. Для динамического режима начальная
+// стоимость — base_prize, DynamicMinimum — нижняя граница, DynamicDecay — число решений,
+// после которого стоимость достигает минимума.
+type IncidentScoring struct {
+	Mode           int32
+	DynamicMinimum int64
+	DynamicDecay   int64
+}
+
+// Value — стоимость инцидента для каждой из solvers решивших команд.
+// Динамическая формула как в CTFd: initial + (minimum-initial)/decay² · (solvers-1)², не ниже minimum.
+func (sc IncidentScoring) Value(basePrize int64, solvers int) int64 {
+	if sc.Mode != IncidentScoringDynamic || solvers <= 1 || sc.DynamicDecay <= 0 {
+		return basePrize
+	}
+	n := int64(solvers - 1)
+	v := basePrize - (basePrize-sc.DynamicMinimum)*n*n/(sc.DynamicDecay*sc.DynamicDecay)
+	if v < sc.DynamicMinimum {
+		v = sc.DynamicMinimum
+	}
+	return v
+}
+
+// IncidentBonuses — бонусы за порядок принятия отчётов по инциденту: первым трём красным
+// командам и самой быстрой синей. Порядок — по времени проверки (reviewed_at), а не reports.time.
+type IncidentBonuses struct {
+	FirstBlood  int64
+	SecondBlood int64
+	ThirdBlood  int64
+	BlueSpeed   int64
+}
+
+// Red — бонус красной команды, принятой place-й по счёту (с 1).
+func (b IncidentBonuses) Red(place int) int64 {
+	switch place {
+	case 1:
+		return b.FirstBlood
+	case 2:
+		return b.SecondBlood
+	case 3:
+		return b.ThirdBlood
+	}
+	return 0
+}
+
+// LedgerEntry — запись журнала очков (score_ledger). Журнал только дополняется: отмена начисления
+// оформляется новой записью с противоположной суммой.
+type LedgerEntry struct {
+	ID         uuid.UUID
+	TeamID     uuid.UUID
+	Kind       int32
+	Amount     int64
+	IncidentID *uuid.UUID
+	ReportID   *uuid.UUID
+	FineID     *uuid.UUID
+	EventID    *uuid.UUID
+	Reason     string
+	CreatedBy  *uuid.UUID
+	CreatedAt  time.Time
+}
+
+func (r *Repo) MigrateLedger(ctx context.Context) error {
+	stmts := []string{
+		`create table if not exists score_ledger(
+			id uuid primary key,
+			team_id uuid not null references teams(id) on delete cascade,
+			kind smallint not null,
+			amount bigint not null,
+			incident_id uuid null,
+			report_id uuid null,
+			fine_id uuid null,
+			reason text not null default '',
+			created_by uuid null,
+			created_at timestamptz not null default now()
+		);`,
+		`create index if not exists idx_score_ledger_team on score_ledger(team_id, created_at);`,
+		`create index if not exists idx_score_ledger_incident on score_ledger(incident_id);`,
+		`alter table score_ledger add column if not exists event_id uuid null;`,
+		`create index if not exists idx_score_ledger_event on score_ledger(event_id);`,
+		`create table if not exists scoreboard_settings(
+			id smallint primary key default 1 check (id = 1),
+			frozen_at timestamptz null,
+			updated_at timestamptz not null default now()
+		);`,
+		`insert into scoreboard_settings(id) values (1) on conflict do nothing;`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return r.backfillLedger(ctx)
+}
+
+// backfillLedger заполняет пустой журнал по уже принятым отчётам и штрафам,
+// сохраняя исходное время событий, чтобы не терять историю счёта.
+func (r *Repo) backfillLedger(ctx context.Context) error {
+	var exists bool
+	if err := r.pool.QueryRow(ctx, `select exists(select 1 from score_ledger)`).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `select id, team_id, event_id, amount, reason, created_at, revoked_at from team_fines`)
+	if err != nil {
+		return err
+	}
+	fines, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (TeamFine, error) {
+		var f TeamFine
+		err := row.Scan(&f.ID, &f.TeamID, &f.EventID, &f.Amount, &f.Reason, &f.CreatedAt, &f.RevokedAt)
+		return f, err
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range fines {
+		fid := f.ID
+		if err := insertLedgerEntry(ctx, tx, &LedgerEntry{TeamID: f.TeamID, Kind: LedgerFine, Amount: -f.Amount, FineID: &fid, EventID: f.EventID, Reason: f.Reason, CreatedAt: f.CreatedAt}); err != nil {
+			return err
+		}
+		if f.RevokedAt != nil {
+			if err := insertLedgerEntry(ctx, tx, &LedgerEntry{TeamID: f.TeamID, Kind: LedgerFineRevocation, Amount: f.Amount, FineID: &fid, EventID: f.EventID, Reason: f.Reason, CreatedAt: *f.RevokedAt}); err != nil {
+				return err
+			}
+		}
+	}
+
+	rows, err = tx.Query(ctx, `select distinct incident_id from reports where status in (2,4)`)
+	if err != nil {
+		return err
+	}
+	incidents, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return err
+	}
+	for _, id := range incidents {
+		if err := settleIncidentLedger(ctx, tx, id, true); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func insertLedgerEntry(ctx context.Context, db execer, e *LedgerEntry) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	_, err := db.Exec(ctx, `insert into score_ledger(id, team_id, kind, amount, incident_id, report_id, fine_id, event_id, reason, created_by, created_at)
+		values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`,
+		e.ID, e.TeamID, e.Kind, e.Amount, e.IncidentID, e.ReportID, e.FineID, e.EventID, e.Reason, e.CreatedBy, e.CreatedAt)
+	return err
+}
+
+type ledgerKey struct {
+	TeamID   uuid.UUID
+	Kind     int32
+	ReportID uuid.UUID
+	EventID  uuid.UUID
+}
+
+// settleIncidentLedger приводит начисления по инциденту в журнале к текущему состоянию отчётов,
+// дописывая разницу. Отчёты разных соревнований считаются независимо. Правила: красные команды
+// с принятым отчётом (в режиме FIRST_ONLY — только первая) получают стоимость инцидента; каждая
+// назначенная на полигон синяя команда-участник теряет её один раз, если принят красный отчёт по её
+// копии; каждая синяя команда с принятым отчётом получает value*pct/100, и эта же доля вычитается
+// из награды красной команды, если атакованная ею копия отражена. Частично принятый отчёт
+// (status=4) считается решением, но все его начисления и списания берутся в доле awarded_percent.
+// backfill=true ставит записям время принятия отчёта вместо текущего.
+// Пересчёты одного инцидента выполняются строго по очереди (advisory lock до конца транзакции),
+// иначе параллельные транзакции дописали бы одну и ту же разницу дважды.
+func settleIncidentLedger(ctx context.Context, tx pgx.Tx, incidentID uuid.UUID, backfill bool) error {
+	if _, err := tx.Exec(ctx, `select pg_advisory_xact_lock(hashtextextended('incident_ledger:' || $1::text, 0))`, incidentID); err != nil {
+		return err
+	}
+	expected := map[ledgerKey]int64{}
+	at := map[ledgerKey]time.Time{}
+
+	var polygonID uuid.UUID
+	var base int64
+	var pct int
+	var scoring IncidentScoring
+	var bonuses IncidentBonuses
+	err := tx.QueryRow(ctx, `select polygon_id, base_prize, blue_share_percent, scoring_mode, dynamic_minimum, dynamic_decay,
+		first_blood_bonus, second_blood_bonus, third_blood_bonus, blue_speed_bonus from incidents where id=$1`, incidentID).
+		Scan(&polygonID, &base, &pct, &scoring.Mode, &scoring.DynamicMinimum, &scoring.DynamicDecay,
+			&bonuses.FirstBlood, &bonuses.SecondBlood, &bonuses.ThirdBlood, &bonuses.BlueSpeed)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		// Инцидент удалён — все его начисления сторнируются.
+	case err != nil:
+		return err
+	default:
+		type solve struct {
+			reportID, teamID uuid.UUID
+			target           *uuid.UUID
+			at               time.Time
+			percent          int64
+		}
+		settleEvent := func(eventID *uuid.UUID) error {
+			var ev uuid.UUID
+			if eventID != nil {
+				ev = *eventID
+			}
+			// Первый принятый отчёт каждой команды, по времени отправки.
+			solves := func(teamType int32) ([]solve, error) {
+				rows, err := tx.Query(ctx, `select id, team_id, target_team_id, reviewed_at, percent from (
+					select distinct on (r.team_id) r.id, r.team_id, r.target_team_id, coalesce(r.reviewed_at, r.updated_at) as reviewed_at, r.created_at,
+						coalesce(r.awarded_percent, 100) as percent
+					from reports r join teams t on t.id=r.team_id
+					where r.incident_id=$1 and r.status in (2,4) and t.type=$2 and r.event_id is not distinct from $3
+					order by r.team_id, r.created_at asc
+				) s order by created_at asc`, incidentID, teamType, eventID)
+				if err != nil {
+					return nil, err
+				}
+				return pgx.CollectRows(rows, func(row pgx.CollectableRow) (solve, error) {
+					var sv solve
+					err := row.Scan(&sv.reportID, &sv.teamID, &sv.target, &sv.at, &sv.percent)
+					return sv, err
+				})
+			}
+			red, err := solves(0)
+			if err != nil {
+				return err
+			}
+			blue, err := solves(1)
+			if err != nil {
+				return err
+			}
+			// Бонусы — по порядку проверки, среди всех принятых красных команд независимо от режима.
+			byReview := func(list []solve) []solve {
+				res := append([]solve(nil), list...)
+				sort.SliceStable(res, func(i, j int) bool { return res[i].at.Before(res[j].at) })
+				return res
+			}
+			for i, rs := range byReview(red) {
+				if b := bonuses.Red(i + 1); b != 0 {
+					k := ledgerKey{rs.teamID, LedgerBonus, rs.reportID, ev}
+					expected[k] += b * rs.percent / 100
+					at[k] = rs.at
+				}
+			}
+			if fastest := byReview(blue); len(fastest) > 0 && bonuses.BlueSpeed != 0 {
+				k := ledgerKey{fastest[0].teamID, LedgerBonus, fastest[0].reportID, ev}
+				expected[k] += bonuses.BlueSpeed * fastest[0].percent / 100
+				at[k] = fastest[0].at
+			}
+
+			if scoring.Mode == IncidentScoringFirstOnly && len(red) > 1 {
+				red = red[:1]
+			}
+			value := scoring.Value(base, len(red))
+			share := (value * int64(pct)) / 100
+
+			// defendedAt — момент первой принятой защиты по каждой копии полигона (ключ — синяя команда).
+			defendedAt := map[uuid.UUID]time.Time{}
+			var anyDefendedAt time.Time
+			for i, b := range blue {
+				if t, ok := defendedAt[b.teamID]; !ok || b.at.Before(t) {
+					defendedAt[b.teamID] = b.at
+				}
+				if i == 0 || b.at.Before(anyDefendedAt) {
+					anyDefendedAt = b.at
+				}
+				if pct > 0 {
+					k := ledgerKey{b.teamID, LedgerBlueShare, b.reportID, ev}
+					expected[k] += share * b.percent / 100
+					at[k] = b.at
+				}
+			}
+
+			for _, rs := range red {
+				award := value * rs.percent / 100
+				k := ledgerKey{rs.teamID, LedgerRedAward, rs.reportID, ev}
+				expected[k] += award
+				at[k] = rs.at
+				// Отчёт без цели (поданный до разделения копий) считается отражённым любой защитой.
+				dAt, defended := anyDefendedAt, len(blue) > 0
+				if rs.target != nil {
+					dAt, defended = defendedAt[*rs.target]
+				}
+				if defended && pct > 0 {
+					k := ledgerKey{rs.teamID, LedgerBlueShare, rs.reportID, ev}
+					expected[k] -= min(share, award)
+					at[k] = rs.at
+					if dAt.After(rs.at) {
+						at[k] = dAt
+					}
+				}
+			}
+			// Каждая синяя команда полигона теряет стоимость инцидента один раз — при первой реализации
+			// на её копии; отчёт без цели задевает все назначенные команды. В соревновании учитываются
+			// только его участники.
+			if len(red) > 0 && value > 0 {
+				rows, err := tx.Query(ctx, `select distinct on (pbt.team_id) pbt.team_id, r.id, coalesce(r.reviewed_at, r.updated_at), coalesce(r.awarded_percent, 100)
+					from reports r
+					join teams t on t.id=r.team_id
+					join polygon_blue_teams pbt on pbt.polygon_id=$2 and (r.target_team_id is null or r.target_team_id=pbt.team_id)
+					where r.incident_id=$1 and r.status in (2,4) and t.type=0 and r.event_id is not distinct from $3
+						and ($3::uuid is null or pbt.team_id in (select team_id from event_teams where event_id=$3))
+					order by pbt.team_id, r.created_at asc`, incidentID, polygonID, eventID)
+				if err != nil {
+					return err
+				}
+				losses, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (solve, error) {
+					var sv solve
+					err := row.Scan(&sv.teamID, &sv.reportID, &sv.at, &sv.percent)
+					return sv, err
+				})
+				if err != nil {
+					return err
+				}
+				for _, l := range losses {
+					k := ledgerKey{l.teamID, LedgerBlueLoss, l.reportID, ev}
+					expected[k] -= value * l.percent / 100
+					at[k] = l.at
+				}
+			}
+			return nil
+		}
+		rows, err := tx.Query(ctx, `select distinct event_id from reports where incident_id=$1 and status in (2,4)`, incidentID)
+		if err != nil {
+			return err
+		}
+		events, err := pgx.CollectRows(rows, pgx.RowTo[*uuid.UUID])
+		if err != nil {
+			return err
+		}
+		for _, ev := range events {
+			if err := settleEvent(ev); err != nil {
+				return err
+			}
+		}
+	}
+
+	current := map[ledgerKey]int64{}
+	rows, err := tx.Query(ctx, `select team_id, kind, report_id, event_id, sum(amount)::bigint from score_ledger
+		where incident_id=$1 and kind in (1,2,3,7) group by team_id, kind, report_id, event_id`, incidentID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var k ledgerKey
+		var rid, eid *uuid.UUID
+		var sum int64
+		if err := rows.Scan(&k.TeamID, &k.Kind, &rid, &eid, &sum); err != nil {
+			rows.Close()
+			return err
+		}
+		if rid != nil {
+			k.ReportID = *rid
+		}
+		if eid != nil {
+			k.EventID = *eid
+		}
+		current[k] = sum
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for k := range current {
+		if _, ok := expected[k]; !ok {
+			expected[k] = 0
+		}
+	}
+
+	for k, want := range expected {
+		diff := want - current[k]
+		if diff == 0 {
+			continue
+		}
+		iid, rid := incidentID, k.ReportID
+		e := &LedgerEntry{TeamID: k.TeamID, Kind: k.Kind, Amount: diff, IncidentID: &iid, ReportID: &rid}
+		if k.EventID != uuid.Nil {
+			eid := k.EventID
+			e.EventID = &eid
+		}
+		if backfill {
+			e.CreatedAt = at[k]
+		}
+		if err := insertLedgerEntry(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settlePolygonLedger пересчитывает начисления по всем инцидентам полигона.
+func settlePolygonLedger(ctx context.Context, tx pgx.Tx, polygonID uuid.UUID) error {
+	rows, err := tx.Query(ctx, `select id from incidents where polygon_id=$1`, polygonID)
+	if err != nil {
+		return err
+	}
+	incidents, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return err
+	}
+	for _, id := range incidents {
+		if err := settleIncidentLedger(ctx, tx, id, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settleEventLedger пересчитывает начисления по инцидентам с принятыми отчётами соревнования.
+func settleEventLedger(ctx context.Context, tx pgx.Tx, eventID uuid.UUID) error {
+	rows, err := tx.Query(ctx, `select distinct incident_id from reports where event_id=$1 and status in (2,4)`, eventID)
+	if err != nil {
+		return err
+	}
+	incidents, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return err
+	}
+	for _, id := range incidents {
+		if err := settleIncidentLedger(ctx, tx, id, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateLedgerAdjustment — ручная корректировка счёта команды администратором.
+func (r *Repo) CreateLedgerAdjustment(ctx context.Context, e *LedgerEntry) error {
+	e.Kind = LedgerManualAdjustment
+	return insertLedgerEntry(ctx, r.pool, e)
+}
+
+func (r *Repo) ListTeamLedger(ctx context.Context, teamID uuid.UUID) ([]LedgerEntry, error) {
+	rows, err := r.pool.Query(ctx, `select id, team_id, kind, amount, incident_id, report_id, fine_id, event_id, reason, created_by, created_at
+		from score_ledger where team_id=$1 order by created_at desc, id`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (LedgerEntry, error) {
+		var e LedgerEntry
+		err := row.Scan(&e.ID, &e.TeamID, &e.Kind, &e.Amount, &e.IncidentID, &e.ReportID, &e.FineID, &e.EventID, &e.Reason, &e.CreatedBy, &e.CreatedAt)
+		return e, err
+	})
+}
+
+// GetScoreboardFreeze возвращает момент заморозки скорборда (nil — не заморожен).
+func (r *Repo) GetScoreboardFreeze(ctx context.Context) (*time.Time, error) {
+	var frozenAt *time.Time
+	err := r.pool.QueryRow(ctx, `select frozen_at from scoreboard_settings where id=1`).Scan(&frozenAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return frozenAt, err
+}
+
+// SetScoreboardFreeze задаёт момент заморозки; nil снимает заморозку.
+func (r *Repo) SetScoreboardFreeze(ctx context.Context, frozenAt *time.Time) error {
+	_, err := r.pool.Exec(ctx, `insert into scoreboard_settings(id, frozen_at) values (1, $1)
+		on conflict (id) do update set frozen_at=excluded.frozen_at, updated_at=now()`, frozenAt)
+	return err
+}
+
+// ListTeamIncidentBonuses — сумма бонусов команды по каждому инциденту в пределах scope.
+func (r *Repo) ListTeamIncidentBonuses(ctx context.Context, teamID uuid.UUID, scope ScoreScope) (map[uuid.UUID]int64, error) {
+	rows, err := r.pool.Query(ctx, `select incident_id, sum(amount)::bigint from score_ledger
+		where team_id=$1 and kind=$2 and incident_id is not null and ($3::timestamptz is null or created_at < $3)
+			and ($4::uuid is null or event_id=$4)
+		group by incident_id`, teamID, LedgerBonus, scope.Until, scope.EventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[uuid.UUID]int64{}
+	for rows.Next() {
+		var iid uuid.UUID
+		var sum int64
+		if err := rows.Scan(&iid, &sum); err != nil {
+			return nil, err
+		}
+		res[iid] = sum
+	}
+	return res, rows.Err()
+}
+
+// ListTeamBonusTotals — сумма бонусов по каждой команде в пределах scope.
+func (r *Repo) ListTeamBonusTotals(ctx context.Context, scope ScoreScope) (map[uuid.UUID]int64, error) {
+	rows, err := r.pool.Query(ctx, `select team_id, sum(amount)::bigint from score_ledger
+		where kind=$1 and ($2::timestamptz is null or created_at < $2) and ($3::uuid is null or event_id=$3)
+		group by team_id`, LedgerBonus, scope.Until, scope.EventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[uuid.UUID]int64{}
+	for rows.Next() {
+		var tid uuid.UUID
+		var sum int64
+		if err := rows.Scan(&tid, &sum); err != nil {
+			return nil, err
+		}
+		res[tid] = sum
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// CyberRange — площадка: описание, собственные исходные материалы (initial_items.range_id)
+// и набор полигонов. Полигон входит не более чем в одну площадку.
+type CyberRange struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	CreatedAt   time.Time
+	PolygonIDs  []uuid.UUID
+}
+
+// ErrPolygonInOtherRange — полигон уже входит в другую площадку.
+var ErrPolygonInOtherRange = errors.New("polygon belongs to another range")
+
+func (r *Repo) MigrateRanges(ctx context.Context) error {
+	stmts := []string{
+		`create table if not exists cyber_ranges(
+			id uuid primary key,
+			name text not null,
+			description text not null default '',
+			created_at timestamptz not null default now(),
+			updated_at timestamptz not null default now()
+		);`,
+		`create table if not exists cyber_range_polygons(
+			range_id uuid not null references cyber_ranges(id) on delete cascade,
+			polygon_id uuid primary key references polygons(id) on delete cascade
+		);`,
+		`create index if not exists idx_cyber_range_polygons_range on cyber_range_polygons(range_id);`,
+		`alter table initial_items add column if not exists range_id uuid null references cyber_ranges(id) on delete cascade;`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const cyberRangeColumns = `id, name, description, created_at`
+
+func scanCyberRange(row pgx.Row, cr *CyberRange) error {
+	return row.Scan(&cr.ID, &cr.Name, &cr.Description, &cr.CreatedAt)
+}
+
+func (r *Repo) CreateCyberRange(ctx context.Context, cr *CyberRange) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := tx.QueryRow(ctx, `insert into cyber_ranges(id, name, description) values ($1,$2,$3) returning created_at`,
+		cr.ID, cr.Name, cr.Description).Scan(&cr.CreatedAt); err != nil {
+		return err
+	}
+	for _, pid := range cr.PolygonIDs {
+		if err := addRangePolygon(ctx, tx, cr.ID, pid); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repo) UpdateCyberRange(ctx context.Context, id uuid.UUID, name, description *string) error {
+	sets := []string{}
+	args := []any{}
+	idx := 1
+	if name != nil {
+		sets = append(sets, "name=$"+strconv.Itoa(idx))
+		args = append(args, *name)
+		idx++
+	}
+	if description != nil {
+		sets = append(sets, "description=$"+strconv.Itoa(idx))
+		args = append(args, *description)
+		idx++
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	sets = append(sets, "updated_at=now()")
+	args = append(args, id)
+	ct, err := r.pool.Exec(ctx, "update cyber_ranges set "+strings.Join(sets, ",")+" where id=$"+strconv.Itoa(idx), args...)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repo) DeleteCyberRange(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `delete from cyber_ranges where id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repo) GetCyberRange(ctx context.Context, id uuid.UUID) (*CyberRange, error) {
+	var cr CyberRange
+	if err := scanCyberRange(r.pool.QueryRow(ctx, `select `+cyberRangeColumns+` from cyber_ranges where id=$1`, id), &cr); err != nil {
+		return nil, err
+	}
+	if err := r.loadRangePolygons(ctx, &cr); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+func (r *Repo) ListCyberRanges(ctx context.Context) ([]CyberRange, error) {
+	rows, err := r.pool.Query(ctx, `select `+cyberRangeColumns+` from cyber_ranges order by name`)
+	if err != nil {
+		return nil, err
+	}
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (CyberRange, error) {
+		var cr CyberRange
+		err := scanCyberRange(row, &cr)
+		return cr, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if err := r.loadRangePolygons(ctx, &list[i]); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// FindCyberRangeByPolygons — площадка, в которую входит больше всего полигонов из polygonIDs.
+// pgx.ErrNoRows — ни один из полигонов не входит в площадку.
+func (r *Repo) FindCyberRangeByPolygons(ctx context.Context, polygonIDs []uuid.UUID) (*CyberRange, error) {
+	var id uuid.UUID
+	err := r.pool.QueryRow(ctx, `select cr.id from cyber_ranges cr
+		join cyber_range_polygons crp on crp.range_id=cr.id
+		where crp.polygon_id = any($1)
+		group by cr.id, cr.name
+		order by count(*) desc, cr.name
+		limit 1`, polygonIDs).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCyberRange(ctx, id)
+}
+
+func (r *Repo) loadRangePolygons(ctx context.Context, cr *CyberRange) error {
+	rows, err := r.pool.Query(ctx, `select crp.polygon_id from cyber_range_polygons crp
+		join polygons p on p.id=crp.polygon_id
+		where crp.range_id=$1 order by p.name`, cr.ID)
+	if err != nil {
+		return err
+	}
+	cr.PolygonIDs, err = pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	return err
+}
+
+func (r *Repo) AddCyberRangePolygon(ctx context.Context, rangeID, polygonID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := addRangePolygon(ctx, tx, rangeID, polygonID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repo) RemoveCyberRangePolygon(ctx context.Context, rangeID, polygonID uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `delete from cyber_range_polygons where range_id=$1 and polygon_id=$2`, rangeID, polygonID)
+	return err
+}
+
+func addRangePolygon(ctx context.Context, tx pgx.Tx, rangeID, polygonID uuid.UUID) error {
+	var owner uuid.UUID
+	err := tx.QueryRow(ctx, `insert into cyber_range_polygons(range_id, polygon_id) values ($1,$2)
+		on conflict (polygon_id) do update set range_id=cyber_range_polygons.range_id
+		returning range_id`, rangeID, polygonID).Scan(&owner)
+	if err != nil {
+		return err
+	}
+	if owner != rangeID {
+		return ErrPolygonInOtherRange
+	}
+	return nil
+}
+
+// ListRangeInitialItems — все исходные материалы площадки без учёта видимости (для администратора).
+func (r *Repo) ListRangeInitialItems(ctx context.Context, rangeID uuid.UUID) ([]InitialItem, error) {
+	rows, err := r.pool.Query(ctx, `select id, name, description, files_urls, user_id, team_id, event_id, range_id
+		from initial_items where range_id=$1 order by name`, rangeID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (InitialItem, error) {
+		var it InitialItem
+		err := row.Scan(&it.ID, &it.Name, &it.Description, &it.Files, &it.UserID, &it.TeamID, &it.EventID, &it.RangeID)
+		return it, err
+	})
+}
@@ -114,6 +114,33 @@ func (r *Repo) Migrate(ctx context.Context) error {
 			revoked_at timestamptz null
 		);`,
 		`create index if not exists idx_team_fines_team on team_fines(team_id);`,
+		`alter table incidents add column if not exists scoring_mode smallint not null default 0;`,
+		`alter table incidents add column if not exists dynamic_minimum bigint not null default 0;`,
+		`alter table incidents add column if not exists dynamic_decay bigint not null default 0;`,
+		`alter table incidents add column if not exists first_blood_bonus bigint not null default 0;`,
+		`alter table incidents add column if not exists second_blood_bonus bigint not null default 0;`,
+		`alter table incidents add column if not exists third_blood_bonus bigint not null default 0;`,
+		`alter table incidents add column if not exists blue_speed_bonus bigint not null default 0;`,
+		// reviewed_at — время проверки отчёта; по нему считается порядок принятия, повторное принятие его не сдвигает.
+		`alter table reports add column if not exists reviewed_at timestamptz null;`,
+		`update reports set reviewed_at=updated_at where reviewed_at is null and status in (2,3);`,
+		// awarded_percent — доля начисления по проверенному отчёту: 100 при ACCEPTED, 1-99 при PARTIALLY_ACCEPTED (status=4).
+		`alter table reports add column if not exists awarded_percent smallint null;`,
+		`update reports set awarded_percent=100 where awarded_percent is null and status=2;`,
+		// polygon_blue_teams — назначение синих команд на полигоны: у каждой своя копия инфраструктуры.
+		`create table if not exists polygon_blue_teams(
+			polygon_id uuid not null references polygons(id) on delete cascade,
+			team_id uuid not null references teams(id) on delete cascade,
+			created_at timestamptz not null default now(),
+			primary key(polygon_id, team_id)
+		);`,
+		`create index if not exists idx_polygon_blue_teams_team on polygon_blue_teams(team_id);`,
+		// Перенос прежней привязки teams.polygon_id (одна синяя команда на полигон); колонка больше не используется.
+		`insert into polygon_blue_teams(polygon_id, team_id) select polygon_id, id from teams where type=1 and polygon_id is not null on conflict do nothing;`,
+		`update teams set polygon_id=null where polygon_id is not null;`,
+		// target_team_id — (для red) синяя команда, чью копию атаковали. Без внешнего ключа: после удаления
+		// команды отчёт остаётся привязан к её копии и не задевает остальные.
+		`alter table reports add column if not exists target_team_id uuid null;`,
 	}
 	for _, s := range stmts {
 		if _, err := r.pool.Exec(ctx, s); err != nil {
@@ -164,14 +191,34 @@ func (r *Repo) UpdateTeam(ctx context.Context, id uuid.UUID, name string, t *int
 	return nil
 }
 func (r *Repo) DeleteTeam(ctx context.Context, id uuid.UUID) error {
-	ct, err := r.pool.Exec(ctx, `delete from teams where id=$1`, id)
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	// Записи журнала самой команды удалятся каскадно; начисления другим командам
+	// по инцидентам с её отчётами пересчитываем после удаления.
+	rows, err := tx.Query(ctx, `select distinct incident_id from reports where team_id=$1 and status in (2,4)`, id)
+	if err != nil {
+		return err
+	}
+	incidents, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return err
+	}
+	ct, err := tx.Exec(ctx, `delete from teams where id=$1`, id)
 	if err != nil {
 		return err
 	}
 	if ct.RowsAffected() == 0 {
 		return pgx.ErrNoRows
 	}
-	return nil
+	for _, iid := range incidents {
+		if err := settleIncidentLedger(ctx, tx, iid, false); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
 }
 func (r *Repo) AddUserToTeam(ctx context.Context, teamID, userID uuid.UUID) error {
 	_, err := r.pool.Exec(ctx, `insert into team_users(team_id,user_id) values ($1,$2)`, teamID, userID)
@@ -290,14 +337,47 @@ func (r *Repo) UpdatePolygon(ctx context.Context, id uuid.UUID, name, descriptio
 	return nil
 }
 
-func (r *Repo) SetTeamPolygon(ctx context.Context, teamID, polygonID uuid.UUID) error {
-	var pid *uuid.UUID
-	if polygonID != uuid.Nil {
-		pid = &polygonID
+// AddPolygonBlueTeam назначает синюю команду на полигон (своя копия инфраструктуры)
+// и пересчитывает начисления по инцидентам полигона.
+func (r *Repo) AddPolygonBlueTeam(ctx context.Context, polygonID, teamID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
 	}
-	_, err := r.pool.Exec(ctx, `update teams set polygon_id=$2, updated_at=now() where id=$1`, teamID, pid)
-	return err
+	defer tx.Rollback(ctx)
+	ct, err := tx.Exec(ctx, `insert into polygon_blue_teams(polygon_id, team_id) values ($1,$2) on conflict do nothing`, polygonID, teamID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return nil
+	}
+	if err := settlePolygonLedger(ctx, tx, polygonID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RemovePolygonBlueTeam снимает синюю команду с полигона; её потери по инцидентам полигона сторнируются.
+func (r *Repo) RemovePolygonBlueTeam(ctx context.Context, polygonID, teamID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	ct, err := tx.Exec(ctx, `delete from polygon_blue_teams where polygon_id=$1 and team_id=$2`, polygonID, teamID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	if err := settlePolygonLedger(ctx, tx, polygonID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
+
 func (r *Repo) DeletePolygon(ctx context.Context, id uuid.UUID) error {
 	ct, err := r.pool.Exec(ctx, `delete from polygons where id=$1`, id)
 	if err != nil {
@@ -317,23 +397,33 @@ func (r *Repo) GetPolygon(ctx context.Context, id uuid.UUID) (*Polygon, error) {
 	return &p, nil
 }
 
-func (r *Repo) FindBlueTeamByPolygon(ctx context.Context, polygonID uuid.UUID) (*Team, error) {
-	row := r.pool.QueryRow(ctx, `select id, name, type from teams where polygon_id=$1 and type=1 limit 1`, polygonID)
-	var t Team
-	if err := row.Scan(&t.ID, &t.Name, &t.Type); err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, nil
-		}
+// ListPolygonBlueTeams — синие команды, назначенные на полигон, в порядке назначения.
+func (r *Repo) ListPolygonBlueTeams(ctx context.Context, polygonID uuid.UUID) ([]Team, error) {
+	rows, err := r.pool.Query(ctx, `select t.id, t.name, t.type from polygon_blue_teams pbt join teams t on t.id=pbt.team_id
+		where pbt.polygon_id=$1 order by pbt.created_at, t.name`, polygonID)
+	if err != nil {
 		return nil, err
 	}
-	return &t, nil
+	defer rows.Close()
+	var res []Team
+	for rows.Next() {
+		var t Team
+		if err := rows.Scan(&t.ID, &t.Name, &t.Type); err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	return res, rows.Err()
 }
 
-func (r *Repo) CreateIncident(ctx context.Context, id, polygonID uuid.UUID, name, description string, basePrize int64, blueSharePercent int) error {
-	_, err := r.pool.Exec(ctx, `insert into incidents(id,polygon_id,name,description,base_prize,blue_share_percent) values ($1,$2,$3,$4,$5,$6)`, id, polygonID, name, description, basePrize, blueSharePercent)
+func (r *Repo) CreateIncident(ctx context.Context, id, polygonID uuid.UUID, name, description string, basePrize int64, blueSharePercent int, scoring IncidentScoring, bonuses IncidentBonuses, review IncidentReviewPolicy) error {
+	_, err := r.pool.Exec(ctx, `insert into incidents(id,polygon_id,name,description,base_prize,blue_share_percent,scoring_mode,dynamic_minimum,dynamic_decay,
+		first_blood_bonus,second_blood_bonus,third_blood_bonus,blue_speed_bonus,review_policy,review_judges,head_judge_id) values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`,
+		id, polygonID, name, description, basePrize, blueSharePercent, scoring.Mode, scoring.DynamicMinimum, scoring.DynamicDecay,
+		bonuses.FirstBlood, bonuses.SecondBlood, bonuses.ThirdBlood, bonuses.BlueSpeed, review.Mode, review.Judges, review.HeadJudgeID)
 	return err
 }
-func (r *Repo) UpdateIncident(ctx context.Context, id uuid.UUID, name, description *string, basePrize *int64, blueSharePercent *int) error {
+func (r *Repo) UpdateIncident(ctx context.Context, id uuid.UUID, name, description *string, basePrize *int64, blueSharePercent *int, scoring *IncidentScoring, bonuses *IncidentBonuses, review *IncidentReviewPolicy) error {
 	sets := []string{}
 	args := []any{}
 	idx := 1
@@ -357,34 +447,68 @@ func (r *Repo) UpdateIncident(ctx context.Context, id uuid.UUID, name, descripti
 		args = append(args, *blueSharePercent)
 		idx++
 	}
+	if scoring != nil {
+		sets = append(sets, "scoring_mode=$"+strconv.Itoa(idx), "dynamic_minimum=$"+strconv.Itoa(idx+1), "dynamic_decay=$"+strconv.Itoa(idx+2))
+		args = append(args, scoring.Mode, scoring.DynamicMinimum, scoring.DynamicDecay)
+		idx += 3
+	}
+	if bonuses != nil {
+		sets = append(sets, "first_blood_bonus=$"+strconv.Itoa(idx), "second_blood_bonus=$"+strconv.Itoa(idx+1),
+			"third_blood_bonus=$"+strconv.Itoa(idx+2), "blue_speed_bonus=$"+strconv.Itoa(idx+3))
+		args = append(args, bonuses.FirstBlood, bonuses.SecondBlood, bonuses.ThirdBlood, bonuses.BlueSpeed)
+		idx += 4
+	}
+	if review != nil {
+		sets = append(sets, "review_policy=$"+strconv.Itoa(idx), "review_judges=$"+strconv.Itoa(idx+1), "head_judge_id=$"+strconv.Itoa(idx+2))
+		args = append(args, review.Mode, review.Judges, review.HeadJudgeID)
+		idx += 3
+	}
 	if len(sets) == 0 {
 		return nil
 	}
 	args = append(args, id)
 	q := "update incidents set " + strings.Join(sets, ",") + ", updated_at=now() where id=$" + strconv.Itoa(idx)
-	ct, err := r.pool.Exec(ctx, q, args...)
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	ct, err := tx.Exec(ctx, q, args...)
 	if err != nil {
 		return err
 	}
 	if ct.RowsAffected() == 0 {
 		return pgx.ErrNoRows
 	}
-	return nil
+	if basePrize != nil || blueSharePercent != nil || scoring != nil || bonuses != nil {
+		if err := settleIncidentLedger(ctx, tx, id, false); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
 }
 func (r *Repo) DeleteIncident(ctx context.Context, id uuid.UUID) error {
-	ct, err := r.pool.Exec(ctx, `delete from incidents where id=$1`, id)
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	ct, err := tx.Exec(ctx, `delete from incidents where id=$1`, id)
 	if err != nil {
 		return err
 	}
 	if ct.RowsAffected() == 0 {
 		return pgx.ErrNoRows
 	}
-	return nil
+	if err := settleIncidentLedger(ctx, tx, id, false); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 func (r *Repo) GetIncident(ctx context.Context, id uuid.UUID) (*Incident, error) {
-	row := r.pool.QueryRow(ctx, `select id, name, description, base_prize, blue_share_percent from incidents where id=$1`, id)
+	row := r.pool.QueryRow(ctx, `select `+incidentColumns+` from incidents where id=$1`, id)
 	var in Incident
-	if err := row.Scan(&in.ID, &in.Name, &in.Description, &in.BasePrize, &in.BlueSharePercent); err != nil {
+	if err := scanIncident(row, &in); err != nil {
 		return nil, err
 	}
 	return &in, nil
@@ -403,22 +527,39 @@ func (r *Repo) GetIncidentPolygonName(ctx context.Context, incidentID uuid.UUID)
 	return name, nil
 }
 
-func (r *Repo) InsertReport(ctx context.Context, id, incidentID, teamID uuid.UUID, redTeamReportID *uuid.UUID, status int32, reportTime int32) error {
-	_, err := r.pool.Exec(ctx, `insert into reports(id,incident_id,team_id,red_team_report_id,status,time) values ($1,$2,$3,$4,$5,$6)`, id, incidentID, teamID, redTeamReportID, status, reportTime)
-	return err
+// InsertReport создаёт отчёт с шагами и его первую версию в одной транзакции.
+func (r *Repo) InsertReport(ctx context.Context, id, incidentID, teamID uuid.UUID, redTeamReportID, targetTeamID, eventID *uuid.UUID, status int32, reportTime int32, steps []ReportStep) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `insert into reports(id,incident_id,team_id,red_team_report_id,target_team_id,event_id,status,time) values ($1,$2,$3,$4,$5,$6,$7,$8)`, id, incidentID, teamID, redTeamReportID, targetTeamID, eventID, status, reportTime); err != nil {
+		return err
+	}
+	if err := insertReportSteps(ctx, tx, id, steps); err != nil {
+		return err
+	}
+	if err := snapshotReportVersion(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
-func (r *Repo) InsertReportSteps(ctx context.Context, reportID uuid.UUID, steps []ReportStep) error {
-	batch := &pgx.Batch{}
+
+func insertReportSteps(ctx context.Context, tx pgx.Tx, reportID uuid.UUID, steps []ReportStep) error {
 	for _, s := range steps {
-		batch.Queue(`insert into report_steps(id,report_id,number,name,time,description,target,source,result) values ($1,$2,$3,$4,$5,$6,$7,$8,$9)`, s.ID, reportID, s.Number, s.Name, s.Time, s.Description, s.Target, s.Source, s.Result)
+		if _, err := tx.Exec(ctx, `insert into report_steps(id,report_id,number,name,time,description,target,source,result) values ($1,$2,$3,$4,$5,$6,$7,$8,$9)`,
+			s.ID, reportID, s.Number, s.Name, s.Time, s.Description, s.Target, s.Source, s.Result); err != nil {
+			return err
+		}
 	}
-	br := r.pool.SendBatch(ctx, batch)
-	return br.Close()
+	return nil
 }
+
 func (r *Repo) GetReport(ctx context.Context, id uuid.UUID) (*Report, error) {
-	row := r.pool.QueryRow(ctx, `select id, incident_id, team_id, red_team_report_id, status, coalesce(rejection_reason,''), time, created_at, updated_at from reports where id=$1`, id)
+	row := r.pool.QueryRow(ctx, `select id, incident_id, team_id, red_team_report_id, target_team_id, event_id, status, coalesce(awarded_percent,0), coalesce(rejection_reason,''), time, created_at, updated_at from reports where id=$1`, id)
 	var rp Report
-	if err := row.Scan(&rp.ID, &rp.IncidentID, &rp.TeamID, &rp.RedTeamReportID, &rp.Status, &rp.RejectionReason, &rp.Time, &rp.CreatedAt, &rp.UpdatedAt); err != nil {
+	if err := row.Scan(&rp.ID, &rp.IncidentID, &rp.TeamID, &rp.RedTeamReportID, &rp.TargetTeamID, &rp.EventID, &rp.Status, &rp.AwardedPercent, &rp.RejectionReason, &rp.Time, &rp.CreatedAt, &rp.UpdatedAt); err != nil {
 		return nil, err
 	}
 	rows, err := r.pool.Query(ctx, `select id, number, coalesce(name,''), coalesce(time,0), coalesce(description,''), coalesce(target,''), coalesce(source,''), coalesce(result,'') from report_steps where report_id=$1 order by number`, id)
@@ -437,7 +578,7 @@ func (r *Repo) GetReport(ctx context.Context, id uuid.UUID) (*Report, error) {
 }
 
 func (r *Repo) ListTeamReports(ctx context.Context, teamID uuid.UUID) ([]Report, error) {
-	rows, err := r.pool.Query(ctx, `select id, incident_id, team_id, red_team_report_id, status, coalesce(rejection_reason,''), time, created_at, updated_at from reports where team_id=$1 order by created_at desc`, teamID)
+	rows, err := r.pool.Query(ctx, `select id, incident_id, team_id, red_team_report_id, target_team_id, event_id, status, coalesce(awarded_percent,0), coalesce(rejection_reason,''), time, created_at, updated_at from reports where team_id=$1 order by created_at desc`, teamID)
 	if err != nil {
 		return nil, err
 	}
@@ -445,7 +586,7 @@ func (r *Repo) ListTeamReports(ctx context.Context, teamID uuid.UUID) ([]Report,
 	var res []Report
 	for rows.Next() {
 		var rp Report
-		if err := rows.Scan(&rp.ID, &rp.IncidentID, &rp.TeamID, &rp.RedTeamReportID, &rp.Status, &rp.RejectionReason, &rp.Time, &rp.CreatedAt, &rp.UpdatedAt); err != nil {
+		if err := rows.Scan(&rp.ID, &rp.IncidentID, &rp.TeamID, &rp.RedTeamReportID, &rp.TargetTeamID, &rp.EventID, &rp.Status, &rp.AwardedPercent, &rp.RejectionReason, &rp.Time, &rp.CreatedAt, &rp.UpdatedAt); err != nil {
 			return nil, err
 		}
 		stRows, err := r.pool.Query(ctx, `select id, number, coalesce(name,''), coalesce(time,0), coalesce(description,''), coalesce(target,''), coalesce(source,''), coalesce(result,'') from report_steps where report_id=$1 order by number`, rp.ID)
@@ -477,24 +618,82 @@ func (r *Repo) GetTeamIncidentReport(ctx context.Context, incidentID, teamID uui
 	}
 	return r.GetReport(ctx, rid)
 }
-func (r *Repo) UpdateReportStatus(ctx context.Context, id uuid.UUID, status int32, reason *string) error {
-	var err error
+
+// lockReportForReview блокирует строку отчёта до конца транзакции и возвращает его статус и признак
+// разногласия судей; pgx.ErrNoRows — отчёта нет.
+func lockReportForReview(ctx context.Context, tx pgx.Tx, id uuid.UUID) (int32, bool, error) {
+	var (
+		st       int32
+		disputed bool
+	)
+	err := tx.QueryRow(ctx, `select status, review_disputed_at is not null from reports where id=$1 for update`, id).Scan(&st, &disputed)
+	return st, disputed, err
+}
+
+// checkReportClaimTx — ErrReportClaimed, если отчёт закреплён за другим судьёй (judgeID=nil — за любым).
+func checkReportClaimTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, judgeID *uuid.UUID) error {
+	var claimed bool
+	if err := tx.QueryRow(ctx, `select exists(select 1 from report_claims where report_id=$1 and judge_id is distinct from $2 and expires_at > now())`,
+		id, judgeID).Scan(&claimed); err != nil {
+		return err
+	}
+	if claimed {
+		return ErrReportClaimed
+	}
+	return nil
+}
+
+// UpdateReportStatus меняет статус отчёта и долю начисления (awardedPercent, nil — не принят),
+// записывает итог проверки в последнюю версию отчёта, снимает захват отчёта судьёй и в той же
+// транзакции дописывает в журнал очков изменения начислений по его инциденту.
+func (r *Repo) UpdateReportStatus(ctx context.Context, id uuid.UUID, status int32, awardedPercent *int32, reason *string, reviewedBy *uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, _, err := lockReportForReview(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := checkReportClaimTx(ctx, tx, id, reviewedBy); err != nil {
+		return err
+	}
+	if err := updateReportStatus(ctx, tx, id, status, awardedPercent, reason, reviewedBy); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// updateReportStatus — UpdateReportStatus внутри транзакции. Повторная проверка уже принятого отчёта
+// с принятием сохраняет reviewed_at: по нему журнал распределяет бонусы первых решений.
+func updateReportStatus(ctx context.Context, tx pgx.Tx, id uuid.UUID, status int32, awardedPercent *int32, reason *string, reviewedBy *uuid.UUID) error {
+	var (
+		incidentID uuid.UUID
+		err        error
+	)
 	if reason != nil {
-		_, err = r.pool.Exec(ctx, `update reports set status=$2, rejection_reason=$3, updated_at=now() where id=$1`, id, status, *reason)
+		err = tx.QueryRow(ctx, `update reports set status=$2, awarded_percent=$3, rejection_reason=$4, reviewed_by=$5, updated_at=now(),
+			reviewed_at=case when status in (2,4) and $2 in (2,4) then coalesce(reviewed_at, updated_at) else now() end, review_disputed_at=null where id=$1 returning incident_id`, id, status, awardedPercent, *reason, reviewedBy).Scan(&incidentID)
 	} else {
-		_, err = r.pool.Exec(ctx, `update reports set status=$2, updated_at=now(), rejection_reason=null where id=$1`, id, status)
+		err = tx.QueryRow(ctx, `update reports set status=$2, awarded_percent=$3, reviewed_by=$4, updated_at=now(),
+			reviewed_at=case when status in (2,4) and $2 in (2,4) then coalesce(reviewed_at, updated_at) else now() end, rejection_reason=null, review_disputed_at=null where id=$1 returning incident_id`, id, status, awardedPercent, reviewedBy).Scan(&incidentID)
 	}
-	return err
-}
-func (r *Repo) ReplaceReportSteps(ctx context.Context, reportID uuid.UUID, steps []ReportStep) error {
-	_, err := r.pool.Exec(ctx, `delete from report_steps where report_id=$1`, reportID)
 	if err != nil {
 		return err
 	}
-	return r.InsertReportSteps(ctx, reportID, steps)
+	if err := recordVersionReview(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `delete from report_claims where report_id=$1`, id); err != nil {
+		return err
+	}
+	return settleIncidentLedger(ctx, tx, incidentID, false)
 }
-func (r *Repo) ReportExistsForTeam(ctx context.Context, incidentID, teamID uuid.UUID) (bool, uuid.UUID, error) {
-	row := r.pool.QueryRow(ctx, `select id from reports where incident_id=$1 and team_id=$2 order by created_at desc limit 1`, incidentID, teamID)
+
+// ReportExistsForTeam ищет отчёт команды по инциденту в соревновании; для красных команд — по конкретной копии полигона.
+func (r *Repo) ReportExistsForTeam(ctx context.Context, incidentID, teamID uuid.UUID, targetTeamID, eventID *uuid.UUID) (bool, uuid.UUID, error) {
+	row := r.pool.QueryRow(ctx, `select id from reports where incident_id=$1 and team_id=$2 and target_team_id is not distinct from $3
+		and event_id is not distinct from $4 order by created_at desc limit 1`, incidentID, teamID, targetTeamID, eventID)
 	var id uuid.UUID
 	err := row.Scan(&id)
 	if err != nil {
@@ -505,43 +704,38 @@ func (r *Repo) ReportExistsForTeam(ctx context.Context, incidentID, teamID uuid.
 	}
 	return true, id, nil
 }
-func (r *Repo) UpdateReportForEdit(ctx context.Context, id uuid.UUID, status int32, newTime int32) error {
-	// При редактировании отклонённого отчёта считаем его повторной отправкой:
-	// 1) сбрасываем rejection_reason
-	// 2) обновляем статус -> PENDING
-	// 3) обновляем логическое поле time (unix timestamp)
-	// 4) «поднимаем» запись через обновление created_at и updated_at
-	_, err := r.pool.Exec(ctx, `update reports set status=$2, rejection_reason=null, time=$3, created_at=now(), updated_at=now() where id=$1`, id, status, newTime)
-	return err
-}
-func (r *Repo) ListReportAttachments(ctx context.Context, reportID uuid.UUID) ([]Attachment, error) {
-	rows, err := r.pool.Query(ctx, `select id, report_id, url, object_key, content_type, size from report_attachments where report_id=$1`, reportID)
+
+// ResubmitReport заменяет шаги отклонённого отчёта и возвращает его на проверку как новую версию:
+// сбрасывает причину отклонения, обновляет time и «поднимает» запись (created_at/updated_at).
+// Всё выполняется в одной транзакции, чтобы проверка не попала в предыдущую версию.
+// pgx.ErrNoRows — отчёт уже не в статусе REJECTED.
+func (r *Repo) ResubmitReport(ctx context.Context, id uuid.UUID, steps []ReportStep, newTime int32) error {
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-	var res []Attachment
-	for rows.Next() {
-		var a Attachment
-		if err := rows.Scan(&a.ID, &a.ReportID, &a.URL, &a.ObjectKey, &a.ContentType, &a.Size); err != nil {
-			return nil, err
-		}
-		res = append(res, a)
+	defer tx.Rollback(ctx)
+	ct, err := tx.Exec(ctx, `update reports set status=1, rejection_reason=null, awarded_percent=null, review_disputed_at=null, time=$2,
+		created_at=now(), updated_at=now() where id=$1 and status=3`, id, newTime)
+	if err != nil {
+		return err
 	}
-	return res, rows.Err()
-}
-
-func (r *Repo) InsertAttachment(ctx context.Context, id, reportID uuid.UUID, url, objectKey, contentType string, size int64) error {
-	_, err := r.pool.Exec(ctx, `insert into report_attachments(id,report_id,url,object_key,content_type,size) values ($1,$2,$3,$4,$5,$6)`, id, reportID, url, objectKey, contentType, size)
-	return err
-}
-func (r *Repo) GetAttachment(ctx context.Context, id uuid.UUID) (*Attachment, error) {
-	row := r.pool.QueryRow(ctx, `select id, report_id, url, object_key, content_type, size from report_attachments where id=$1`, id)
-	var a Attachment
-	if err := row.Scan(&a.ID, &a.ReportID, &a.URL, &a.ObjectKey, &a.ContentType, &a.Size); err != nil {
-		return nil, err
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	if _, err := tx.Exec(ctx, `delete from report_steps where report_id=$1`, id); err != nil {
+		return err
+	}
+	if err := insertReportSteps(ctx, tx, id, steps); err != nil {
+		return err
+	}
+	if err := detachRemovedStepAttachments(ctx, tx, id, int32(len(steps))); err != nil {
+		return err
 	}
-	return &a, nil
+	if err := snapshotReportVersion(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *Repo) ListPolygonsWithIncidents(ctx context.Context) ([]PolygonWithIncidents, error) {
@@ -562,13 +756,13 @@ func (r *Repo) ListPolygonsWithIncidents(ctx context.Context) ([]PolygonWithInci
 		return nil, rows.Err()
 	}
 	for i := range polys {
-		ir, err := r.pool.Query(ctx, `select id, name, description, base_prize, blue_share_percent from incidents where polygon_id=$1 order by created_at`, polys[i].ID)
+		ir, err := r.pool.Query(ctx, `select `+incidentColumns+` from incidents where polygon_id=$1 order by created_at`, polys[i].ID)
 		if err != nil {
 			return nil, err
 		}
 		for ir.Next() {
 			var in Incident
-			if err := ir.Scan(&in.ID, &in.Name, &in.Description, &in.BasePrize, &in.BlueSharePercent); err != nil {
+			if err := scanIncident(ir, &in); err != nil {
 				ir.Close()
 				return nil, err
 			}
@@ -579,7 +773,7 @@ func (r *Repo) ListPolygonsWithIncidents(ctx context.Context) ([]PolygonWithInci
 	return polys, nil
 }
 func (r *Repo) ListIncidents(ctx context.Context, polygonID uuid.UUID) ([]Incident, error) {
-	rows, err := r.pool.Query(ctx, `select id, name, description, base_prize, blue_share_percent from incidents where polygon_id=$1 order by created_at`, polygonID)
+	rows, err := r.pool.Query(ctx, `select `+incidentColumns+` from incidents where polygon_id=$1 order by created_at`, polygonID)
 	if err != nil {
 		return nil, err
 	}
@@ -587,7 +781,7 @@ func (r *Repo) ListIncidents(ctx context.Context, polygonID uuid.UUID) ([]Incide
 	res := []Incident{}
 	for rows.Next() {
 		var in Incident
-		if err := rows.Scan(&in.ID, &in.Name, &in.Description, &in.BasePrize, &in.BlueSharePercent); err != nil {
+		if err := scanIncident(rows, &in); err != nil {
 			return nil, err
 		}
 		res = append(res, in)
@@ -595,16 +789,19 @@ func (r *Repo) ListIncidents(ctx context.Context, polygonID uuid.UUID) ([]Incide
 	return res, rows.Err()
 }
 
-func (r *Repo) ListInitialItems(ctx context.Context, userID *uuid.UUID, teamIDs []uuid.UUID) ([]InitialItem, error) {
+func (r *Repo) ListInitialItems(ctx context.Context, userID *uuid.UUID, teamIDs []uuid.UUID, eventID, rangeID *uuid.UUID) ([]InitialItem, error) {
 	var rows pgx.Rows
 	var err error
 	// Логика видимости:
 	// 1. Публичные (user_id IS NULL AND team_id IS NULL)
 	// 2. user_id = текущий пользователь
 	// 3. team_id IN (команды пользователя)
+	// Плюс: элементы соревнования видны только в нём, общие (event_id IS NULL) — везде;
+	// материалы площадки (range_id) отдаются только вместе с ней, общие — только без неё.
+	const eventCond = "(event_id is null or event_id=$1) and range_id is not distinct from $2"
 	if userID != nil || len(teamIDs) > 0 {
-		args := []any{}
-		idx := 1
+		args := []any{eventID, rangeID}
+		idx := 3
 		conds := []string{"(user_id is null and team_id is null)"}
 		if userID != nil {
 			conds = append(conds, "user_id=$"+strconv.Itoa(idx))
@@ -620,11 +817,12 @@ func (r *Repo) ListInitialItems(ctx context.Context, userID *uuid.UUID, teamIDs
 			}
 			conds = append(conds, "team_id in ("+strings.Join(inPh, ",")+")")
 		}
-		where := strings.Join(conds, " OR ")
-		q := "select id, name, description, files_urls, user_id, team_id from initial_items where " + where + " order by name"
+		where := "(" + strings.Join(conds, " OR ") + ") and " + eventCond
+		q := "select id, name, description, files_urls, user_id, team_id, event_id, range_id from initial_items where " + where + " order by name"
 		rows, err = r.pool.Query(ctx, q, args...)
 	} else {
-		rows, err = r.pool.Query(ctx, `select id, name, description, files_urls, user_id, team_id from initial_items where user_id is null and team_id is null order by name`)
+		rows, err = r.pool.Query(ctx, `select id, name, description, files_urls, user_id, team_id, event_id, range_id from initial_items
+			where user_id is null and team_id is null and `+eventCond+` order by name`, eventID, rangeID)
 	}
 	if err != nil {
 		return nil, err
@@ -633,7 +831,7 @@ func (r *Repo) ListInitialItems(ctx context.Context, userID *uuid.UUID, teamIDs
 	var res []InitialItem
 	for rows.Next() {
 		var it InitialItem
-		if err := rows.Scan(&it.ID, &it.Name, &it.Description, &it.Files, &it.UserID, &it.TeamID); err != nil {
+		if err := rows.Scan(&it.ID, &it.Name, &it.Description, &it.Files, &it.UserID, &it.TeamID, &it.EventID, &it.RangeID); err != nil {
 			return nil, err
 		}
 		res = append(res, it)
@@ -646,7 +844,10 @@ type Report struct {
 	IncidentID      uuid.UUID
 	TeamID          uuid.UUID
 	RedTeamReportID *uuid.UUID
+	TargetTeamID    *uuid.UUID // (для red) синяя команда, чью копию полигона атаковали
+	EventID         *uuid.UUID
 	Status          int32
+	AwardedPercent  int32 // 0, если отчёт не принят
 	RejectionReason string
 	Time            int32
 	Steps           []ReportStep
@@ -665,15 +866,6 @@ type ReportStep struct {
 	Result      string
 }
 
-type Attachment struct {
-	ID          uuid.UUID
-	ReportID    uuid.UUID
-	URL         string
-	ObjectKey   string
-	ContentType string
-	Size        int64
-}
-
 type PolygonWithIncidents struct {
 	ID          uuid.UUID
 	Name        string
@@ -684,10 +876,22 @@ type PolygonWithIncidents struct {
 
 type Incident struct {
 	ID               uuid.UUID
+	PolygonID        uuid.UUID
 	Name             string
 	Description      string
 	BasePrize        int64
 	BlueSharePercent int
+	Scoring          IncidentScoring
+	Bonuses          IncidentBonuses
+	Review           IncidentReviewPolicy
+}
+
+const incidentColumns = `id, polygon_id, name, description, base_prize, blue_share_percent, scoring_mode, dynamic_minimum, dynamic_decay,
+	first_blood_bonus, second_blood_bonus, third_blood_bonus, blue_speed_bonus, review_policy, review_judges, head_judge_id`
+
+func scanIncident(row pgx.Row, in *Incident) error {
+	return row.Scan(&in.ID, &in.PolygonID, &in.Name, &in.Description, &in.BasePrize, &in.BlueSharePercent, &in.Scoring.Mode, &in.Scoring.DynamicMinimum, &in.Scoring.DynamicDecay,
+		&in.Bonuses.FirstBlood, &in.Bonuses.SecondBlood, &in.Bonuses.ThirdBlood, &in.Bonuses.BlueSpeed, &in.Review.Mode, &in.Review.Judges, &in.Review.HeadJudgeID)
 }
 
 type InitialItem struct {
@@ -697,6 +901,8 @@ type InitialItem struct {
 	Files       []string
 	UserID      *uuid.UUID
 	TeamID      *uuid.UUID
+	EventID     *uuid.UUID // если задано, видно только участникам соревнования
+	RangeID     *uuid.UUID // если задано, материал площадки
 }
 
 type Polygon struct {
@@ -714,6 +920,7 @@ type Team struct {
 type TeamFine struct {
 	ID        uuid.UUID
 	TeamID    uuid.UUID
+	EventID   *uuid.UUID
 	Amount    int64
 	Reason    string
 	CreatedAt time.Time
@@ -741,7 +948,7 @@ func (r *Repo) ListReportsByIncidentsAndType(ctx context.Context, incidentIDs []
 	}
 	params = append(params, teamType)
 
-	q := `select r.id, r.incident_id, r.team_id, r.red_team_report_id, r.status, coalesce(r.rejection_reason,''), coalesce(r.time,0), r.created_at, r.updated_at
+	q := `select r.id, r.incident_id, r.team_id, r.red_team_report_id, r.target_team_id, r.event_id, r.status, coalesce(r.awarded_percent,0), coalesce(r.rejection_reason,''), coalesce(r.time,0), r.created_at, r.updated_at
 		  from reports r join teams t on t.id = r.team_id
 		  where r.incident_id in (` + strings.Join(ph, ",") + `) and t.type = $` + strconv.Itoa(len(incidentIDs)+1) + `
 		  order by r.created_at desc`
@@ -755,7 +962,7 @@ func (r *Repo) ListReportsByIncidentsAndType(ctx context.Context, incidentIDs []
 	var reportIDs []uuid.UUID
 	for rows.Next() {
 		var rp Report
-		if err := rows.Scan(&rp.ID, &rp.IncidentID, &rp.TeamID, &rp.RedTeamReportID, &rp.Status, &rp.RejectionReason, &rp.Time, &rp.CreatedAt, &rp.UpdatedAt); err != nil {
+		if err := rows.Scan(&rp.ID, &rp.IncidentID, &rp.TeamID, &rp.RedTeamReportID, &rp.TargetTeamID, &rp.EventID, &rp.Status, &rp.AwardedPercent, &rp.RejectionReason, &rp.Time, &rp.CreatedAt, &rp.UpdatedAt); err != nil {
 			return nil, err
 		}
 		res[rp.IncidentID] = append(res[rp.IncidentID], rp)
@@ -819,15 +1026,21 @@ func (r *Repo) GetLatestReportForTeam(ctx context.Context, incidentID, teamID uu
 	return st, reason, nil
 }
 
-func (r *Repo) GetLatestReportMetaForTeam(ctx context.Context, incidentID, teamID uuid.UUID) (uuid.UUID, int32, *string, error) {
-	row := r.pool.QueryRow(ctx, `select id, status, rejection_reason from reports where incident_id=$1 and team_id=$2 order by created_at desc limit 1`, incidentID, teamID)
-	var rid uuid.UUID
-	var st int32
-	var reason *string
-	if err := row.Scan(&rid, &st, &reason); err != nil {
-		return uuid.Nil, 0, nil, err
+// ReportMeta — статус последнего отчёта команды по инциденту.
+type ReportMeta struct {
+	ID              uuid.UUID
+	Status          int32
+	AwardedPercent  int32
+	RejectionReason *string
+}
+
+func (r *Repo) GetLatestReportMetaForTeam(ctx context.Context, incidentID, teamID uuid.UUID, eventID *uuid.UUID) (*ReportMeta, error) {
+	row := r.pool.QueryRow(ctx, `select id, status, coalesce(awarded_percent,0), rejection_reason from reports where incident_id=$1 and team_id=$2 and event_id is not distinct from $3 order by created_at desc limit 1`, incidentID, teamID, eventID)
+	var m ReportMeta
+	if err := row.Scan(&m.ID, &m.Status, &m.AwardedPercent, &m.RejectionReason); err != nil {
+		return nil, err
 	}
-	return rid, st, reason, nil
+	return &m, nil
 }
 
 func (r *Repo) GetLatestReportStatusesByType(ctx context.Context, incidentIDs []uuid.UUID, teamType int32) ([]LatestReportStatus, error) {
@@ -937,138 +1150,65 @@ func (r *Repo) ListTeamUserIDs(ctx context.Context, teamID uuid.UUID) ([]uuid.UU
 	return ids, rows.Err()
 }
 
-func (r *Repo) ListTeamPrizes(ctx context.Context) (map[uuid.UUID]int64, error) {
-	res := make(map[uuid.UUID]int64)
-	// --- Соберём инциденты, по которым есть успешная защита синей команды (accepted blue report) ---
-	blueIncRows, err := r.pool.Query(ctx, `select distinct r.incident_id
-		from reports r join teams t on t.id=r.team_id
-		where r.status=2 and t.type=1`)
+// ListTeamPrizes возвращает сумму начислений журнала очков по каждой команде (без initial_prize)
+// в пределах scope: только записи соревнования и до момента заморозки скорборда.
+func (r *Repo) ListTeamPrizes(ctx context.Context, scope ScoreScope) (map[uuid.UUID]int64, error) {
+	rows, err := r.pool.Query(ctx, `select team_id, sum(amount)::bigint from score_ledger
+		where ($1::timestamptz is null or created_at < $1) and ($2::uuid is null or event_id=$2)
+		group by team_id`, scope.Until, scope.EventID)
 	if err != nil {
 		return nil, err
 	}
-	blueDefended := map[uuid.UUID]struct{}{}
-	for blueIncRows.Next() {
-		var iid uuid.UUID
-		if err := blueIncRows.Scan(&iid); err != nil {
-			blueIncRows.Close()
+	defer rows.Close()
+	res := make(map[uuid.UUID]int64)
+	for rows.Next() {
+		var tid uuid.UUID
+		var sum int64
+		if err := rows.Scan(&tid, &sum); err != nil {
 			return nil, err
 		}
-		blueDefended[iid] = struct{}{}
-	}
-	blueIncRows.Close()
-	if err := blueIncRows.Err(); err != nil {
-		return nil, err
+		res[tid] = sum
 	}
+	return res, rows.Err()
+}
 
-	// Карта: polygon_id -> blue team id (предполагаем по одному blue per polygon)
-	blueTeamByPolygon := map[uuid.UUID]uuid.UUID{}
-	polygonBlueRows, err := r.pool.Query(ctx, `select id, polygon_id from teams where type=1 and polygon_id is not null`)
-	if err == nil { // если ошибка - просто пропустим вычитание (лучше чем фейлить весь расчёт)
-		for polygonBlueRows.Next() {
-			var bid, pid uuid.UUID
-			if err2 := polygonBlueRows.Scan(&bid, &pid); err2 != nil {
-				polygonBlueRows.Close()
-				break
-			}
-			blueTeamByPolygon[pid] = bid
-		}
-		polygonBlueRows.Close()
-	}
-
-	// Только первая принятая (accepted) красная команда по каждому инциденту получает базовый приз.
-	// DISTINCT ON (incident_id) + order by created_at asc — самая ранняя accepted красная сдача.
-	// Дополнительно выбираем polygon_id инцидента чтобы штрафовать (отнимать) у defending blue команды.
-	redRows, err := r.pool.Query(ctx, `select distinct on (r.incident_id) r.team_id, r.incident_id, i.base_prize, i.blue_share_percent, i.polygon_id
-		from reports r
-		join teams t on t.id=r.team_id
-		join incidents i on i.id=r.incident_id
-		where r.status=2 and t.type=0
-		order by r.incident_id, r.created_at asc`)
-	if err != nil {
-		return nil, err
-	}
-	for redRows.Next() {
-		var teamID, incID, polygonID uuid.UUID
-		var base int64
-		var pct int
-		if err := redRows.Scan(&teamID, &incID, &base, &pct, &polygonID); err != nil {
-			redRows.Close()
-			return nil, err
-		}
-		delta := base
-		if _, defended := blueDefended[incID]; defended && pct > 0 { // если защищён — забираем долю синей команды
-			share := (base * int64(pct)) / 100
-			if share < delta {
-				delta -= share
-			} else {
-				delta = 0
-			}
-		}
-		// Начисляем красной команде её итоговую награду
-		res[teamID] += delta
-		// Отнимаем полный базовый приз (base), а не delta, чтобы синие не сохраняли выгоду от своей доли.
-		// Требование: у синей команды отнимается сумма стоимости инцидента при успешной реализации красными.
-		if btid, ok := blueTeamByPolygon[polygonID]; ok && base > 0 {
-			res[btid] -= base
-		}
-	}
-	redRows.Close()
-	if err := redRows.Err(); err != nil {
-		return nil, err
-	}
+// ScoreEvent — изменение счёта команды в момент Time.
+type ScoreEvent struct {
+	TeamID uuid.UUID
+	Time   time.Time
+	Delta  int64
+}
 
-	blueRows, err := r.pool.Query(ctx, `select distinct on (r.incident_id, r.team_id) r.team_id, r.incident_id, i.base_prize, i.blue_share_percent
-		from reports r
-		join teams t on t.id=r.team_id
-		join incidents i on i.id=r.incident_id
-		where r.status=2 and t.type=1
-		order by r.incident_id, r.team_id, r.created_at asc`)
+// ListScoreEvents — изменения счёта команд по возрастанию Time: стартовый капитал на момент
+// создания команды (для соревнования — не раньше его начала) и записи журнала очков в пределах scope.
+func (r *Repo) ListScoreEvents(ctx context.Context, scope ScoreScope) ([]ScoreEvent, error) {
+	rows, err := r.pool.Query(ctx, `select id, greatest(created_at, coalesce((select starts_at from events where id=$2), created_at)), initial_prize from teams
+		union all
+		select team_id, created_at, amount from score_ledger
+		where ($1::timestamptz is null or created_at < $1) and ($2::uuid is null or event_id=$2)
+		order by 2`, scope.Until, scope.EventID)
 	if err != nil {
 		return nil, err
 	}
-	for blueRows.Next() {
-		var teamID, incID uuid.UUID
-		var base int64
-		var pct int
-		if err := blueRows.Scan(&teamID, &incID, &base, &pct); err != nil {
-			blueRows.Close()
-			return nil, err
-		}
-		if pct > 0 {
-			res[teamID] += (base * int64(pct)) / 100
-		}
-	}
-	blueRows.Close()
-	if err := blueRows.Err(); err != nil {
-		return nil, err
-	}
-	// Вычитаем активные штрафы
-	fineRows, err := r.pool.Query(ctx, `select team_id, amount from team_fines where revoked_at is null`)
-	if err != nil {
-		return nil, err
-	}
-	for fineRows.Next() {
-		var tid uuid.UUID
-		var amount int64
-		if err := fineRows.Scan(&tid, &amount); err != nil {
-			fineRows.Close()
+	defer rows.Close()
+	var res []ScoreEvent
+	for rows.Next() {
+		var e ScoreEvent
+		if err := rows.Scan(&e.TeamID, &e.Time, &e.Delta); err != nil {
 			return nil, err
 		}
-		res[tid] -= amount
+		res = append(res, e)
 	}
-	fineRows.Close()
-	if err := fineRows.Err(); err != nil {
-		return nil, err
-	}
-	return res, nil
+	return res, rows.Err()
 }
 
 // ListTeamReportCounts returns per-team counts of total submitted reports and accepted reports.
-// Map value: [0] = submitted, [1] = accepted.
-func (r *Repo) ListTeamReportCounts(ctx context.Context) (map[uuid.UUID][2]uint32, error) {
+// Map value: [0] = submitted, [1] = accepted. If scope.Until is set, only reports accepted before it
+// (by reviewed_at, as in the ledger) are counted as accepted; if scope.EventID is set, only reports of that event are counted.
+func (r *Repo) ListTeamReportCounts(ctx context.Context, scope ScoreScope) (map[uuid.UUID][2]uint32, error) {
 	res := make(map[uuid.UUID][2]uint32)
 	// Submitted counts
-	rows, err := r.pool.Query(ctx, `select team_id, count(*) from reports group by team_id`)
+	rows, err := r.pool.Query(ctx, `select team_id, count(*) from reports where $1::uuid is null or event_id=$1 group by team_id`, scope.EventID)
 	if err != nil {
 		return nil, err
 	}
@@ -1086,7 +1226,8 @@ func (r *Repo) ListTeamReportCounts(ctx context.Context) (map[uuid.UUID][2]uint3
 		return nil, err
 	}
 	// Accepted counts
-	arows, err := r.pool.Query(ctx, `select team_id, count(*) from reports where status=2 group by team_id`)
+	arows, err := r.pool.Query(ctx, `select team_id, count(*) from reports where status in (2,4) and ($1::timestamptz is null or coalesce(reviewed_at, updated_at) < $1)
+		and ($2::uuid is null or event_id=$2) group by team_id`, scope.Until, scope.EventID)
 	if err != nil {
 		return nil, err
 	}
@@ -1116,35 +1257,65 @@ func (r *Repo) GetUserTeam(ctx context.Context, userID uuid.UUID) (*Team, error)
 	return &t, nil
 }
 
-func (r *Repo) GetTeamPolygonID(ctx context.Context, teamID uuid.UUID) (uuid.UUID, error) {
-	row := r.pool.QueryRow(ctx, `select coalesce(polygon_id, '00000000-0000-0000-0000-000000000000') from teams where id=$1`, teamID)
-	var pid uuid.UUID
-	if err := row.Scan(&pid); err != nil {
-		return uuid.Nil, err
-	}
-	if pid == uuid.Nil {
-		return uuid.Nil, nil
+// ListTeamPolygonIDs — полигоны, на которые назначена синяя команда.
+func (r *Repo) ListTeamPolygonIDs(ctx context.Context, teamID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.pool.Query(ctx, `select polygon_id from polygon_blue_teams where team_id=$1 order by created_at`, teamID)
+	if err != nil {
+		return nil, err
 	}
-	return pid, nil
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
 }
 
 // --- Штрафы команд ---
-func (r *Repo) CreateTeamFine(ctx context.Context, id, teamID uuid.UUID, amount int64, reason string) error {
-	_, err := r.pool.Exec(ctx, `insert into team_fines(id, team_id, amount, reason) values ($1,$2,$3,$4)`, id, teamID, amount, reason)
-	return err
+func (r *Repo) CreateTeamFine(ctx context.Context, id, teamID uuid.UUID, eventID *uuid.UUID, amount int64, reason string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var createdAt time.Time
+	if err := tx.QueryRow(ctx, `insert into team_fines(id, team_id, event_id, amount, reason) values ($1,$2,$3,$4,$5) returning created_at`, id, teamID, eventID, amount, reason).Scan(&createdAt); err != nil {
+		return err
+	}
+	if err := insertLedgerEntry(ctx, tx, &LedgerEntry{TeamID: teamID, Kind: LedgerFine, Amount: -amount, FineID: &id, EventID: eventID, Reason: reason, CreatedAt: createdAt}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 func (r *Repo) RevokeTeamFine(ctx context.Context, id uuid.UUID) error {
-	ct, err := r.pool.Exec(ctx, `update team_fines set revoked_at=now() where id=$1 and revoked_at is null`, id)
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	if ct.RowsAffected() == 0 {
-		return pgx.ErrNoRows
+	defer tx.Rollback(ctx)
+	if err := revokeTeamFine(ctx, tx, id); err != nil {
+		return err
 	}
-	return nil
+	return tx.Commit(ctx)
+}
+
+// revokeTeamFine отзывает активный штраф и возвращает его сумму через журнал; pgx.ErrNoRows — штрафа нет или он уже отозван.
+func revokeTeamFine(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {
+	var f TeamFine
+	err := tx.QueryRow(ctx, `update team_fines set revoked_at=now() where id=$1 and revoked_at is null returning team_id, event_id, amount, reason, revoked_at`, id).Scan(&f.TeamID, &f.EventID, &f.Amount, &f.Reason, &f.RevokedAt)
+	if err != nil {
+		return err
+	}
+	return insertLedgerEntry(ctx, tx, &LedgerEntry{TeamID: f.TeamID, Kind: LedgerFineRevocation, Amount: f.Amount, FineID: &id, EventID: f.EventID, Reason: f.Reason, CreatedAt: *f.RevokedAt})
+}
+
+// GetTeamFine — штраф по id.
+func (r *Repo) GetTeamFine(ctx context.Context, id uuid.UUID) (*TeamFine, error) {
+	var f TeamFine
+	err := r.pool.QueryRow(ctx, `select id, team_id, event_id, amount, reason, created_at, revoked_at from team_fines where id=$1`, id).
+		Scan(&f.ID, &f.TeamID, &f.EventID, &f.Amount, &f.Reason, &f.CreatedAt, &f.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
 }
 func (r *Repo) ListTeamFines(ctx context.Context, teamID uuid.UUID) ([]TeamFine, error) {
-	rows, err := r.pool.Query(ctx, `select id, team_id, amount, reason, created_at, revoked_at from team_fines where team_id=$1 order by created_at desc`, teamID)
+	rows, err := r.pool.Query(ctx, `select id, team_id, event_id, amount, reason, created_at, revoked_at from team_fines where team_id=$1 order by created_at desc`, teamID)
 	if err != nil {
 		return nil, err
 	}
@@ -1152,7 +1323,7 @@ func (r *Repo) ListTeamFines(ctx context.Context, teamID uuid.UUID) ([]TeamFine,
 	var res []TeamFine
 	for rows.Next() {
 		var f TeamFine
-		if err := rows.Scan(&f.ID, &f.TeamID, &f.Amount, &f.Reason, &f.CreatedAt, &f.RevokedAt); err != nil {
+		if err := rows.Scan(&f.ID, &f.TeamID, &f.EventID, &f.Amount, &f.Reason, &f.CreatedAt, &f.RevokedAt); err != nil {
 			return nil, err
 		}
 		res = append(res, f)
@@ -1166,28 +1337,37 @@ type AcceptedRedReportSummary struct {
 	IncidentName        string
 	IncidentDescription string
 	TeamID              uuid.UUID
+	TargetTeamID        *uuid.UUID
 	Time                int32
+	AwardedPercent      int32
 	BasePrize           int64
 	BlueSharePercent    int
+	Scoring             IncidentScoring
+	Bonuses             IncidentBonuses
 }
 
-func (r *Repo) ListAcceptedRedReports(ctx context.Context, incidentIDs []uuid.UUID) ([]AcceptedRedReportSummary, error) {
+// ListAcceptedRedReports — принятые (в том числе частично) красные отчёты по инцидентам;
+// eventID != nil — только отчёты соревнования.
+func (r *Repo) ListAcceptedRedReports(ctx context.Context, incidentIDs []uuid.UUID, eventID *uuid.UUID) ([]AcceptedRedReportSummary, error) {
 	if len(incidentIDs) == 0 {
 		return nil, nil
 	}
-	params := make([]any, 0, len(incidentIDs)+2)
+	params := make([]any, 0, len(incidentIDs)+3)
 	ph := make([]string, 0, len(incidentIDs))
 	for i, id := range incidentIDs {
 		params = append(params, id)
 		ph = append(ph, "$"+strconv.Itoa(i+1))
 	}
-	params = append(params, int32(2))
+	params = append(params, []int32{2, 4})
 	params = append(params, int32(0))
-	q := `select r.id, r.incident_id, i.name, i.description, r.team_id, r.time, i.base_prize, i.blue_share_percent
+	params = append(params, eventID)
+	q := `select r.id, r.incident_id, i.name, i.description, r.team_id, r.target_team_id, r.time, coalesce(r.awarded_percent, 100), i.base_prize, i.blue_share_percent, i.scoring_mode, i.dynamic_minimum, i.dynamic_decay,
+		  i.first_blood_bonus, i.second_blood_bonus, i.third_blood_bonus, i.blue_speed_bonus
 		  from reports r
 		  join incidents i on i.id=r.incident_id
 		  join teams t on t.id=r.team_id
-		  where r.incident_id in (` + strings.Join(ph, ",") + `) and r.status=$` + strconv.Itoa(len(incidentIDs)+1) + ` and t.type=$` + strconv.Itoa(len(incidentIDs)+2) + `
+		  where r.incident_id in (` + strings.Join(ph, ",") + `) and r.status = any($` + strconv.Itoa(len(incidentIDs)+1) + `) and t.type=$` + strconv.Itoa(len(incidentIDs)+2) + `
+		  and ($` + strconv.Itoa(len(incidentIDs)+3) + `::uuid is null or r.event_id=$` + strconv.Itoa(len(incidentIDs)+3) + `)
 		  order by r.created_at`
 	rows, err := r.pool.Query(ctx, q, params...)
 	if err != nil {
@@ -1197,7 +1377,8 @@ func (r *Repo) ListAcceptedRedReports(ctx context.Context, incidentIDs []uuid.UU
 	var res []AcceptedRedReportSummary
 	for rows.Next() {
 		var a AcceptedRedReportSummary
-		if err := rows.Scan(&a.ReportID, &a.IncidentID, &a.IncidentName, &a.IncidentDescription, &a.TeamID, &a.Time, &a.BasePrize, &a.BlueSharePercent); err != nil {
+		if err := rows.Scan(&a.ReportID, &a.IncidentID, &a.IncidentName, &a.IncidentDescription, &a.TeamID, &a.TargetTeamID, &a.Time, &a.AwardedPercent, &a.BasePrize, &a.BlueSharePercent, &a.Scoring.Mode, &a.Scoring.DynamicMinimum, &a.Scoring.DynamicDecay,
+			&a.Bonuses.FirstBlood, &a.Bonuses.SecondBlood, &a.Bonuses.ThirdBlood, &a.Bonuses.BlueSpeed); err != nil {
 			return nil, err
 		}
 		res = append(res, a)
@@ -1205,11 +1386,11 @@ func (r *Repo) ListAcceptedRedReports(ctx context.Context, incidentIDs []uuid.UU
 	return res, rows.Err()
 }
 
-func (r *Repo) CreateInitialItem(ctx context.Context, id uuid.UUID, name, description string, files []string, userID, teamID *uuid.UUID) error {
-	_, err := r.pool.Exec(ctx, `insert into initial_items(id,name,description,files_urls,user_id,team_id) values($1,$2,$3,$4,$5,$6)`, id, name, description, files, userID, teamID)
+func (r *Repo) CreateInitialItem(ctx context.Context, id uuid.UUID, name, description string, files []string, userID, teamID, eventID, rangeID *uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `insert into initial_items(id,name,description,files_urls,user_id,team_id,event_id,range_id) values($1,$2,$3,$4,$5,$6,$7,$8)`, id, name, description, files, userID, teamID, eventID, rangeID)
 	return err
 }
-func (r *Repo) UpdateInitialItem(ctx context.Context, id uuid.UUID, name, description *string, files *[]string, userIDSet bool, userID *uuid.UUID, teamIDSet bool, teamID *uuid.UUID) error {
+func (r *Repo) UpdateInitialItem(ctx context.Context, id uuid.UUID, name, description *string, files *[]string, userIDSet bool, userID *uuid.UUID, teamIDSet bool, teamID *uuid.UUID, eventIDSet bool, eventID *uuid.UUID, rangeIDSet bool, rangeID *uuid.UUID) error {
 	sets := []string{}
 	args := []any{}
 	idx := 1
@@ -1238,6 +1419,16 @@ func (r *Repo) UpdateInitialItem(ctx context.Context, id uuid.UUID, name, descri
 		args = append(args, teamID)
 		idx++
 	}
+	if eventIDSet {
+		sets = append(sets, "event_id=$"+strconv.Itoa(idx))
+		args = append(args, eventID)
+		idx++
+	}
+	if rangeIDSet {
+		sets = append(sets, "range_id=$"+strconv.Itoa(idx))
+		args = append(args, rangeID)
+		idx++
+	}
 	if len(sets) == 0 {
 		return nil
 	}
@@ -1263,9 +1454,9 @@ func (r *Repo) DeleteInitialItem(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 func (r *Repo) GetInitialItem(ctx context.Context, id uuid.UUID) (*InitialItem, error) {
-	row := r.pool.QueryRow(ctx, `select id,name,description,files_urls,user_id,team_id from initial_items where id=$1`, id)
+	row := r.pool.QueryRow(ctx, `select id,name,description,files_urls,user_id,team_id,event_id,range_id from initial_items where id=$1`, id)
 	var it InitialItem
-	if err := row.Scan(&it.ID, &it.Name, &it.Description, &it.Files, &it.UserID, &it.TeamID); err != nil {
+	if err := row.Scan(&it.ID, &it.Name, &it.Description, &it.Files, &it.UserID, &it.TeamID, &it.EventID, &it.RangeID); err != nil {
 		return nil, err
 	}
 	return &it, nil
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReportVersion — неизменяемый снимок отчёта на момент отправки или редактирования: шаги
+// и время отправки не меняются, итог проверки (статус, причина, проверяющий) дописывается
+// в последнюю версию при ревью.
+type ReportVersion struct {
+	ID              uuid.UUID
+	ReportID        uuid.UUID
+	Number          int32
+	Status          int32
+	AwardedPercent  int32
+	RejectionReason string
+	ReviewedBy      *uuid.UUID
+	ReviewedAt      *time.Time
+	Time            int32
+	CreatedAt       time.Time
+	Steps           []ReportStep
+}
+
+func (r *Repo) MigrateReportVersions(ctx context.Context) error {
+	stmts := []string{
+		`alter table reports add column if not exists reviewed_by uuid null;`,
+		`create table if not exists report_versions(
+			id uuid primary key,
+			report_id uuid not null references reports(id) on delete cascade,
+			number int not null,
+			status smallint not null,
+			awarded_percent smallint null,
+			rejection_reason text null,
+			reviewed_by uuid null,
+			reviewed_at timestamptz null,
+			time int not null default 0,
+			created_at timestamptz not null default now(),
+			unique(report_id, number)
+		);`,
+		`create table if not exists report_version_steps(
+			id uuid primary key,
+			version_id uuid not null references report_versions(id) on delete cascade,
+			number int not null,
+			name text not null default '',
+			time int not null default 0,
+			description text not null default '',
+			target text not null default '',
+			source text not null default '',
+			result text not null default ''
+		);`,
+		`create index if not exists idx_report_version_steps_version on report_version_steps(version_id);`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	// Отчёты, отправленные до появления версий, получают версию 1 из текущего состояния.
+	rows, err := r.pool.Query(ctx, `select id from reports r where not exists (select 1 from report_versions v where v.report_id=r.id)`)
+	if err != nil {
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := r.SnapshotReportVersion(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapshotReportVersion сохраняет текущее состояние отчёта и его шагов как новую версию.
+func (r *Repo) SnapshotReportVersion(ctx context.Context, reportID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := snapshotReportVersion(ctx, tx, reportID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// snapshotReportVersion — SnapshotReportVersion внутри транзакции: после отправки, каждого
+// редактирования, возврата на проверку по апелляции и при повторной проверке.
+func snapshotReportVersion(ctx context.Context, tx pgx.Tx, reportID uuid.UUID) error {
+	vid := uuid.New()
+	if _, err := tx.Exec(ctx, `insert into report_versions(id, report_id, number, status, awarded_percent, rejection_reason, reviewed_by, reviewed_at, time, created_at)
+		select $2, r.id, coalesce((select max(number) from report_versions where report_id=r.id), 0) + 1,
+			r.status, r.awarded_percent, r.rejection_reason,
+			case when r.status=1 then null else r.reviewed_by end,
+			case when r.status=1 then null else r.reviewed_at end,
+			coalesce(r.time, 0), r.created_at
+		from reports r where r.id=$1`, reportID, vid); err != nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, `select number, coalesce(name,''), coalesce(time,0), coalesce(description,''), coalesce(target,''), coalesce(source,''), coalesce(result,'')
+		from report_steps where report_id=$1 order by number`, reportID)
+	if err != nil {
+		return err
+	}
+	steps, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ReportStep, error) {
+		var s ReportStep
+		err := row.Scan(&s.Number, &s.Name, &s.Time, &s.Description, &s.Target, &s.Source, &s.Result)
+		return s, err
+	})
+	if err != nil {
+		return err
+	}
+	for _, s := range steps {
+		if _, err := tx.Exec(ctx, `insert into report_version_steps(id, version_id, number, name, time, description, target, source, result)
+			values ($1,$2,$3,$4,$5,$6,$7,$8,$9)`, uuid.New(), vid, s.Number, s.Name, s.Time, s.Description, s.Target, s.Source, s.Result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordVersionReview дописывает итог проверки в последнюю версию отчёта, если она ещё не проверена.
+// Проверенная версия не меняется: повторная проверка сохраняется новой версией.
+func recordVersionReview(ctx context.Context, tx pgx.Tx, reportID uuid.UUID) error {
+	const q = `update report_versions v set status=r.status, awarded_percent=r.awarded_percent, rejection_reason=r.rejection_reason,
+			reviewed_by=r.reviewed_by, reviewed_at=r.reviewed_at
+		from reports r
+		where r.id=$1 and v.id=(select id from report_versions where report_id=$1 order by number desc limit 1) and v.status=1`
+	ct, err := tx.Exec(ctx, q, reportID)
+	if err != nil || ct.RowsAffected() > 0 {
+		return err
+	}
+	if err := openReviewVersion(ctx, tx, reportID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, q, reportID)
+	return err
+}
+
+// openReviewVersion начинает для повторной проверки новую версию отчёта без итога проверки,
+// чтобы вердикты и итог не попали в уже проверенную версию.
+func openReviewVersion(ctx context.Context, tx pgx.Tx, reportID uuid.UUID) error {
+	if err := snapshotReportVersion(ctx, tx, reportID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `update report_versions set status=1, awarded_percent=null, rejection_reason=null, reviewed_by=null, reviewed_at=null
+		where id=(select id from report_versions where report_id=$1 order by number desc limit 1)`, reportID)
+	return err
+}
+
+const reportVersionColumns = `id, report_id, number, status, coalesce(awarded_percent,0), coalesce(rejection_reason,''), reviewed_by, reviewed_at, time, created_at`
+
+func scanReportVersion(row pgx.Row, v *ReportVersion) error {
+	return row.Scan(&v.ID, &v.ReportID, &v.Number, &v.Status, &v.AwardedPercent, &v.RejectionReason, &v.ReviewedBy, &v.ReviewedAt, &v.Time, &v.CreatedAt)
+}
+
+// ListReportVersions — версии отчёта по возрастанию номера, с шагами.
+func (r *Repo) ListReportVersions(ctx context.Context, reportID uuid.UUID) ([]ReportVersion, error) {
+	rows, err := r.pool.Query(ctx, `select `+reportVersionColumns+` from report_versions where report_id=$1 order by number`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ReportVersion, error) {
+		var v ReportVersion
+		err := scanReportVersion(row, &v)
+		return v, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Steps, err = r.listReportVersionSteps(ctx, list[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// GetReportVersion — версия отчёта по номеру; number=0 — последняя.
+func (r *Repo) GetReportVersion(ctx context.Context, reportID uuid.UUID, number int32) (*ReportVersion, error) {
+	var v ReportVersion
+	err := scanReportVersion(r.pool.QueryRow(ctx, `select `+reportVersionColumns+` from report_versions
+		where report_id=$1 and ($2=0 or number=$2) order by number desc limit 1`, reportID, number), &v)
+	if err != nil {
+		return nil, err
+	}
+	if v.Steps, err = r.listReportVersionSteps(ctx, v.ID); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *Repo) listReportVersionSteps(ctx context.Context, versionID uuid.UUID) ([]ReportStep, error) {
+	rows, err := r.pool.Query(ctx, `select id, number, name, time, description, target, source, result
+		from report_version_steps where version_id=$1 order by number`, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ReportStep, error) {
+		var s ReportStep
+		err := row.Scan(&s.ID, &s.Number, &s.Name, &s.Time, &s.Description, &s.Target, &s.Source, &s.Result)
+		return s, err
+	})
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Порядок очереди проверки (значения совпадают с pb.ReviewQueueSort).
+const (
+	ReviewQueueSortOldest int32 = 0
+	ReviewQueueSortNewest int32 = 1
+	ReviewQueueSortPrize  int32 = 2
+)
+
+// ReportClaim — судья, взявший отчёт на проверку, и срок, до которого отчёт закреплён за ним.
+type ReportClaim struct {
+	ReportID  uuid.UUID
+	JudgeID   uuid.UUID
+	ClaimedAt time.Time
+	ExpiresAt time.Time
+}
+
+// ErrReportClaimed — отчёт закреплён за другим судьёй.
+var ErrReportClaimed = errors.New("report claimed by another judge")
+
+// ReviewQueueFilter — фильтры очереди PENDING отчётов. Нулевые значения не ограничивают выборку.
+type ReviewQueueFilter struct {
+	EventID    *uuid.UUID
+	PolygonID  *uuid.UUID
+	IncidentID *uuid.UUID
+	TeamType   *int32
+	MinAge     time.Duration // ожидает проверки не меньше
+	MaxAge     time.Duration // ожидает проверки не больше
+	// HideClaimedFor — скрыть отчёты с действующим захватом другого судьи.
+	HideClaimedFor *uuid.UUID
+	Sort           int32
+	Limit          int32
+	Offset         int32
+}
+
+// ReviewQueueEntry — отчёт в очереди и его текущее закрепление (nil, если нет или истекло).
+type ReviewQueueEntry struct {
+	ReportID  uuid.UUID
+	CreatedAt time.Time
+	Claim     *ReportClaim
+}
+
+func (r *Repo) MigrateReviewQueue(ctx context.Context) error {
+	stmts := []string{
+		`create table if not exists report_claims(
+			report_id uuid primary key references reports(id) on delete cascade,
+			judge_id uuid not null,
+			claimed_at timestamptz not null default now(),
+			expires_at timestamptz not null
+		);`,
+		`create index if not exists idx_reports_status_created on reports(status, created_at);`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListReviewQueue — страница PENDING отчётов всех команд и общее число подходящих под фильтр.
+func (r *Repo) ListReviewQueue(ctx context.Context, f ReviewQueueFilter) ([]ReviewQueueEntry, int32, error) {
+	conds := []string{"r.status=1"}
+	args := []any{}
+	idx := 1
+	add := func(cond string, v any) {
+		conds = append(conds, strings.ReplaceAll(cond, "$?", "$"+strconv.Itoa(idx)))
+		args = append(args, v)
+		idx++
+	}
+	if f.EventID != nil {
+		add("r.event_id=$?", *f.EventID)
+	}
+	if f.PolygonID != nil {
+		add("i.polygon_id=$?", *f.PolygonID)
+	}
+	if f.IncidentID != nil {
+		add("r.incident_id=$?", *f.IncidentID)
+	}
+	if f.TeamType != nil {
+		add("t.type=$?", *f.TeamType)
+	}
+	if f.MinAge > 0 {
+		add("r.created_at <= now() - make_interval(secs => $?)", f.MinAge.Seconds())
+	}
+	if f.MaxAge > 0 {
+		add("r.created_at >= now() - make_interval(secs => $?)", f.MaxAge.Seconds())
+	}
+	if f.HideClaimedFor != nil {
+		add("(c.report_id is null or c.expires_at <= now() or c.judge_id=$?)", *f.HideClaimedFor)
+	}
+	order := "r.created_at asc"
+	switch f.Sort {
+	case ReviewQueueSortNewest:
+		order = "r.created_at desc"
+	case ReviewQueueSortPrize:
+		order = "i.base_prize desc, r.created_at asc"
+	}
+	args = append(args, f.Limit, f.Offset)
+	q := `select r.id, r.created_at, c.judge_id, c.claimed_at, c.expires_at, count(*) over()
+		from reports r
+		join incidents i on i.id=r.incident_id
+		join teams t on t.id=r.team_id
+		left join report_claims c on c.report_id=r.id
+		where ` + strings.Join(conds, " and ") + `
+		order by ` + order + `
+		limit $` + strconv.Itoa(idx) + ` offset $` + strconv.Itoa(idx+1)
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var (
+		res   []ReviewQueueEntry
+		total int64
+	)
+	for rows.Next() {
+		var (
+			e         ReviewQueueEntry
+			judgeID   *uuid.UUID
+			claimedAt *time.Time
+			expiresAt *time.Time
+		)
+		if err := rows.Scan(&e.ReportID, &e.CreatedAt, &judgeID, &claimedAt, &expiresAt, &total); err != nil {
+			return nil, 0, err
+		}
+		if judgeID != nil && expiresAt.After(time.Now()) {
+			e.Claim = &ReportClaim{ReportID: e.ReportID, JudgeID: *judgeID, ClaimedAt: *claimedAt, ExpiresAt: *expiresAt}
+		}
+		res = append(res, e)
+	}
+	return res, int32(total), rows.Err()
+}
+
+// ClaimReport закрепляет PENDING отчёт за судьёй на ttl. Свой или истёкший захват продлевается;
+// действующий захват другого судьи — ErrReportClaimed (возвращается вместе с ним).
+// pgx.ErrNoRows — отчёта нет или он уже не PENDING. Строка отчёта блокируется на чтение, поэтому
+// захват не пересекается с проверкой, которая в этот момент меняет его статус.
+func (r *Repo) ClaimReport(ctx context.Context, reportID, judgeID uuid.UUID, ttl time.Duration) (*ReportClaim, error) {
+	c := ReportClaim{ReportID: reportID}
+	err := r.pool.QueryRow(ctx, `insert into report_claims(report_id, judge_id, claimed_at, expires_at)
+		select $1, $2, now(), now()+make_interval(secs => $3)
+		where exists (select 1 from reports where id=$1 and status=1 for share)
+		on conflict (report_id) do update set judge_id=excluded.judge_id,
+			claimed_at=case when report_claims.judge_id=excluded.judge_id and report_claims.expires_at > now() then report_claims.claimed_at else excluded.claimed_at end,
+			expires_at=excluded.expires_at
+		where report_claims.judge_id=excluded.judge_id or report_claims.expires_at <= now()
+		returning judge_id, claimed_at, expires_at`, reportID, judgeID, ttl.Seconds()).Scan(&c.JudgeID, &c.ClaimedAt, &c.ExpiresAt)
+	if err == nil {
+		return &c, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	// Вставки не было: либо отчёт не PENDING, либо его держит другой судья.
+	cur, err := r.GetReportClaim(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if cur.JudgeID == judgeID {
+		return nil, pgx.ErrNoRows
+	}
+	return cur, ErrReportClaimed
+}
+
+// GetReportClaim — действующий захват отчёта; pgx.ErrNoRows, если его нет или он истёк.
+func (r *Repo) GetReportClaim(ctx context.Context, reportID uuid.UUID) (*ReportClaim, error) {
+	c := ReportClaim{ReportID: reportID}
+	err := r.pool.QueryRow(ctx, `select judge_id, claimed_at, expires_at from report_claims where report_id=$1 and expires_at > now()`, reportID).
+		Scan(&c.JudgeID, &c.ClaimedAt, &c.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ReleaseReportClaim снимает захват отчёта; judgeID=nil — независимо от того, чей он.
+func (r *Repo) ReleaseReportClaim(ctx context.Context, reportID uuid.UUID, judgeID *uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `delete from report_claims where report_id=$1 and ($2::uuid is null or judge_id=$2 or expires_at <= now())`, reportID, judgeID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Политика проверки отчётов инцидента (значения совпадают с pb.ReviewPolicy).
+const (
+	ReviewPolicySingle     int32 = 0 // решает первый же вердикт
+	ReviewPolicyNApprovals int32 = 1 // нужно Judges одобрений без единого отклонения
+	ReviewPolicyMajority   int32 = 2 // решает большинство из Judges судей
+)
+
+// IncidentReviewPolicy — сколько судей и как решают судьбу отчёта по инциденту.
+// HeadJudgeID разрешает разногласия; nil — любой администратор.
+type IncidentReviewPolicy struct {
+	Mode        int32
+	Judges      int32
+	HeadJudgeID *uuid.UUID
+}
+
+// ReportVerdict — решение одного судьи по версии отчёта. Head — решение главного судьи по разногласию.
+type ReportVerdict struct {
+	ReportID       uuid.UUID
+	Version        int32
+	JudgeID        uuid.UUID
+	Status         int32
+	AwardedPercent int32
+	Reason         string
+	Head           bool
+	CreatedAt      time.Time
+}
+
+func (r *Repo) MigrateVerdicts(ctx context.Context) error {
+	stmts := []string{
+		`alter table incidents add column if not exists review_policy smallint not null default 0;`,
+		`alter table incidents add column if not exists review_judges int not null default 1;`,
+		`alter table incidents add column if not exists head_judge_id uuid null;`,
+		`alter table reports add column if not exists review_disputed_at timestamptz null;`,
+		`create table if not exists report_verdicts(
+			report_id uuid not null references reports(id) on delete cascade,
+			version int not null,
+			judge_id uuid not null,
+			status smallint not null,
+			awarded_percent smallint null,
+			reason text not null default '',
+			head boolean not null default false,
+			created_at timestamptz not null default now(),
+			primary key(report_id, version, judge_id)
+		);`,
+	}
+	for _, s := range stmts {
+		if _, err := r.pool.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReviewOutcome — итог проверки отчёта в виде аргументов UpdateReportStatus.
+type ReviewOutcome struct {
+	Status         int32
+	AwardedPercent *int32  // nil — не принят
+	Reason         *string // только при REJECTED
+}
+
+// ReviewDecider выводит итог проверки из вердиктов по последней версии отчёта. nil — итога ещё нет;
+// disputed — судьи разошлись и решение за главным судьёй.
+type ReviewDecider func(verdicts []ReportVerdict) (outcome *ReviewOutcome, disputed bool)
+
+var (
+	// ErrReportDecided — отчёт уже не ждёт проверки.
+	ErrReportDecided = errors.New("report already decided")
+	// ErrReportNotDisputed — по отчёту нет разногласия, которое мог бы разрешить главный судья.
+	ErrReportNotDisputed = errors.New("report has no dispute")
+)
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// RecordReportVerdict записывает вердикт судьи и по всем вердиктам последней версии решает судьбу отчёта:
+// если decide даёт итог, статус меняется как в UpdateReportStatus, при разногласии отчёт помечается
+// для главного судьи. Всё выполняется в одной транзакции под блокировкой строки отчёта, чтобы
+// одновременные вердикты не разошлись с итогом. Возвращает true, если статус отчёта изменён.
+// При коллегиальной проверке (collegial) вердикт принимается только по PENDING отчёту (иначе
+// ErrReportDecided), захват отчёта не мешает голосовать остальным судьям, а захват голосующего
+// снимается после промежуточного вердикта; иначе захват другого судьи — ErrReportClaimed.
+func (r *Repo) RecordReportVerdict(ctx context.Context, v *ReportVerdict, collegial bool, decide ReviewDecider) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	st, _, err := lockReportForReview(ctx, tx, v.ReportID)
+	if err != nil {
+		return false, err
+	}
+	if collegial && st != 1 {
+		return false, ErrReportDecided
+	}
+	if !collegial {
+		if err := checkReportClaimTx(ctx, tx, v.ReportID, &v.JudgeID); err != nil {
+			return false, err
+		}
+		if st != 1 {
+			if err := openReviewVersion(ctx, tx, v.ReportID); err != nil {
+				return false, err
+			}
+		}
+	}
+	if err := upsertReportVerdict(ctx, tx, v); err != nil {
+		return false, err
+	}
+	verdicts, err := listReportVerdicts(ctx, tx, v.ReportID)
+	if err != nil {
+		return false, err
+	}
+	o, disputed := decide(verdicts)
+	if o == nil {
+		if disputed {
+			if _, err := tx.Exec(ctx, `update reports set review_disputed_at=coalesce(review_disputed_at, now()) where id=$1 and status=1`, v.ReportID); err != nil {
+				return false, err
+			}
+		}
+		if _, err := tx.Exec(ctx, `delete from report_claims where report_id=$1 and judge_id=$2`, v.ReportID, v.JudgeID); err != nil {
+			return false, err
+		}
+		return false, tx.Commit(ctx)
+	}
+	if err := updateReportStatus(ctx, tx, v.ReportID, o.Status, o.AwardedPercent, o.Reason, &v.JudgeID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// ResolveReportDispute записывает решение главного судьи (v.Head) и в той же транзакции применяет его
+// к отчёту; захваты рядовых судей ему не мешают. ErrReportNotDisputed — отчёт уже не PENDING или
+// разногласия по нему нет.
+func (r *Repo) ResolveReportDispute(ctx context.Context, v *ReportVerdict, o *ReviewOutcome) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	st, disputed, err := lockReportForReview(ctx, tx, v.ReportID)
+	if err != nil {
+		return err
+	}
+	if st != 1 || !disputed {
+		return ErrReportNotDisputed
+	}
+	if err := upsertReportVerdict(ctx, tx, v); err != nil {
+		return err
+	}
+	if err := updateReportStatus(ctx, tx, v.ReportID, o.Status, o.AwardedPercent, o.Reason, &v.JudgeID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// upsertReportVerdict записывает (или заменяет) вердикт судьи по последней версии отчёта.
+func upsertReportVerdict(ctx context.Context, tx pgx.Tx, v *ReportVerdict) error {
+	return tx.QueryRow(ctx, `insert into report_verdicts(report_id, version, judge_id, status, awarded_percent, reason, head)
+		values ($1, coalesce((select max(number) from report_versions where report_id=$1), 1), $2, $3, $4, $5, $6)
+		on conflict (report_id, version, judge_id) do update set status=excluded.status, awarded_percent=excluded.awarded_percent,
+			reason=excluded.reason, head=excluded.head, created_at=now()
+		returning version, created_at`, v.ReportID, v.JudgeID, v.Status, v.AwardedPercent, v.Reason, v.Head).Scan(&v.Version, &v.CreatedAt)
+}
+
+// ListReportVerdicts — вердикты по последней версии отчёта в порядке поступления.
+func (r *Repo) ListReportVerdicts(ctx context.Context, reportID uuid.UUID) ([]ReportVerdict, error) {
+	return listReportVerdicts(ctx, r.pool, reportID)
+}
+
+func listReportVerdicts(ctx context.Context, db querier, reportID uuid.UUID) ([]ReportVerdict, error) {
+	rows, err := db.Query(ctx, `select report_id, version, judge_id, status, coalesce(awarded_percent,0), reason, head, created_at
+		from report_verdicts
+		where report_id=$1 and version=coalesce((select max(number) from report_versions where report_id=$1), 1)
+		order by created_at`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ReportVerdict, error) {
+		var v ReportVerdict
+		err := row.Scan(&v.ReportID, &v.Version, &v.JudgeID, &v.Status, &v.AwardedPercent, &v.Reason, &v.Head, &v.CreatedAt)
+		return v, err
+	})
+}
+
+// GetReportDisputedAt — момент возникновения разногласия по отчёту, nil — разногласия нет.
+func (r *Repo) GetReportDisputedAt(ctx context.Context, reportID uuid.UUID) (*time.Time, error) {
+	var t *time.Time
+	err := r.pool.QueryRow(ctx, `select review_disputed_at from reports where id=$1`, reportID).Scan(&t)
+	return t, err
+}
+
+// ListDisputedReports — PENDING отчёты с разногласием судей, старые первыми; eventID=nil — все.
+func (r *Repo) ListDisputedReports(ctx context.Context, eventID *uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.pool.Query(ctx, `select id from reports
+		where status=1 and review_disputed_at is not null and ($1::uuid is null or event_id=$1)
+		order by review_disputed_at`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}